@@ -0,0 +1,304 @@
+// Package cgroup reads the resources accounted to a process control group, on both the legacy
+// (v1) and the unified (v2) hierarchies
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robinmonjo/dock/procfs"
+)
+
+const (
+	cgroupV1FSType = "cgroup"
+	cgroupV2FSType = "cgroup2"
+
+	// v1 reports "no limit" as a page aligned max int64
+	unlimitedV1 = uint64(1) << 62
+
+	unlimitedV2 = "max"
+)
+
+// ErrControllerNotFound is returned when the requested controller isn't mounted or isn't
+// enabled for the cgroup
+var ErrControllerNotFound = errors.New("cgroup controller not found")
+
+// Cgroup gives access to the resources of a process cgroup
+type Cgroup struct {
+	// Version of the hierarchy, 1 (legacy) or 2 (unified)
+	Version int
+
+	// cgroup directories indexed by controller. On the unified hierarchy every
+	// controller lives in the same directory, stored under the "" key
+	dirs map[string]string
+}
+
+// MemoryStats stores memory usage and limit, in bytes. A zero Limit means no limit
+type MemoryStats struct {
+	Usage    uint64
+	MaxUsage uint64 // v1 only
+	Limit    uint64
+}
+
+// CPUStats stores CPU usage and throttling (CFS bandwidth control)
+type CPUStats struct {
+	Usage            time.Duration
+	Periods          uint64
+	ThrottledPeriods uint64
+	ThrottledTime    time.Duration
+}
+
+// PidsStats stores the number of tasks in the cgroup. A zero Limit means no limit
+type PidsStats struct {
+	Current uint64
+	Limit   uint64
+}
+
+// MemoryEvents stores the number of memory events that occurred in the cgroup. On v1
+// only OOMKill is reported (kernel >= 4.13)
+type MemoryEvents struct {
+	Low     uint64
+	High    uint64
+	Max     uint64
+	OOM     uint64
+	OOMKill uint64
+}
+
+// Self returns the cgroup of the current process
+func Self() (*Cgroup, error) {
+	return Open(procfs.Self(), "/")
+}
+
+// Open returns the cgroup of the given process. Cgroups are located using /proc/$PID/cgroup and
+// /proc/$PID/mountinfo, root is prepended to the mount points found (i.e. "/" or a snapshot directory)
+func Open(p *procfs.Proc, root string) (*Cgroup, error) {
+	cgroups, err := p.Cgroups()
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := p.MountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	v1 := &Cgroup{Version: 1, dirs: map[string]string{}}
+	v2 := &Cgroup{Version: 2, dirs: map[string]string{}}
+
+	for _, cg := range cgroups {
+		for _, m := range mounts {
+			switch {
+			case cg.HierarchyID == 0 && m.FSType == cgroupV2FSType:
+				v2.dirs[""] = cgroupDir(root, m, cg.Path)
+			case cg.HierarchyID != 0 && m.FSType == cgroupV1FSType:
+				for _, controller := range cg.Controllers {
+					if include(m.SuperOptions, controller) {
+						v1.dirs[controller] = cgroupDir(root, m, cg.Path)
+					}
+				}
+			}
+		}
+	}
+
+	// hybrid setups mount the unified hierarchy next to v1 controllers, without
+	// any resource controller on it. Prefer v1 as soon as it provides a controller
+	if len(v1.dirs) > 0 {
+		return v1, nil
+	}
+	if len(v2.dirs) > 0 {
+		return v2, nil
+	}
+	return nil, ErrControllerNotFound
+}
+
+// the cgroup path is relative to the hierarchy root, while the mount may expose only a
+// sub tree of it (i.e. /docker/<id> mounted on /sys/fs/cgroup/memory inside the container)
+func cgroupDir(root string, m *procfs.Mount, path string) string {
+	rel := path
+	if m.Root != "/" {
+		if path == m.Root || strings.HasPrefix(path, m.Root+"/") {
+			rel = strings.TrimPrefix(path, m.Root)
+		}
+	}
+	return filepath.Join(root, m.MountPoint, rel)
+}
+
+func (c *Cgroup) dir(controller string) (string, error) {
+	if c.Version == 2 {
+		controller = ""
+	}
+	d, ok := c.dirs[controller]
+	if !ok {
+		return "", ErrControllerNotFound
+	}
+	return d, nil
+}
+
+// Memory returns the memory usage and limit of the cgroup
+func (c *Cgroup) Memory() (*MemoryStats, error) {
+	dir, err := c.dir("memory")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MemoryStats{}
+	if c.Version == 1 {
+		if s.Usage, err = readUint(dir, "memory.usage_in_bytes"); err != nil {
+			return nil, err
+		}
+		if s.MaxUsage, err = readUint(dir, "memory.max_usage_in_bytes"); err != nil {
+			return nil, err
+		}
+		if s.Limit, err = readUint(dir, "memory.limit_in_bytes"); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	if s.Usage, err = readUint(dir, "memory.current"); err != nil {
+		return nil, err
+	}
+	if s.Limit, err = readUint(dir, "memory.max"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MemoryEvents returns the memory events counters of the cgroup
+func (c *Cgroup) MemoryEvents() (*MemoryEvents, error) {
+	dir, err := c.dir("memory")
+	if err != nil {
+		return nil, err
+	}
+
+	e := &MemoryEvents{}
+	if c.Version == 1 {
+		values, err := readKeyValues(dir, "memory.oom_control")
+		if err != nil {
+			return nil, err
+		}
+		e.OOMKill = values["oom_kill"]
+		return e, nil
+	}
+
+	values, err := readKeyValues(dir, "memory.events")
+	if err != nil {
+		return nil, err
+	}
+	e.Low = values["low"]
+	e.High = values["high"]
+	e.Max = values["max"]
+	e.OOM = values["oom"]
+	e.OOMKill = values["oom_kill"]
+	return e, nil
+}
+
+// CPU returns the CPU usage and throttling of the cgroup
+func (c *Cgroup) CPU() (*CPUStats, error) {
+	dir, err := c.dir("cpu")
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := readKeyValues(dir, "cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &CPUStats{
+		Periods:          values["nr_periods"],
+		ThrottledPeriods: values["nr_throttled"],
+	}
+
+	if c.Version == 2 {
+		s.Usage = time.Duration(values["usage_usec"]) * time.Microsecond
+		s.ThrottledTime = time.Duration(values["throttled_usec"]) * time.Microsecond
+		return s, nil
+	}
+
+	s.ThrottledTime = time.Duration(values["throttled_time"]) //nanoseconds
+	if dir, err := c.dir("cpuacct"); err == nil {
+		usage, err := readUint(dir, "cpuacct.usage")
+		if err != nil {
+			return nil, err
+		}
+		s.Usage = time.Duration(usage)
+	}
+	return s, nil
+}
+
+// Pids returns the number of tasks in the cgroup and its limit
+func (c *Cgroup) Pids() (*PidsStats, error) {
+	dir, err := c.dir("pids")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PidsStats{}
+	if s.Current, err = readUint(dir, "pids.current"); err != nil {
+		return nil, err
+	}
+	if s.Limit, err = readUint(dir, "pids.max"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// read a single value file, "no limit" values are returned as 0
+func readUint(dir, file string) (uint64, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(b))
+	if value == unlimitedV2 {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v >= unlimitedV1 {
+		return 0, nil
+	}
+	return v, nil
+}
+
+// read flat keyed files (i.e. cpu.stat, memory.events)
+func readKeyValues(dir, file string) (map[string]uint64, error) {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = v
+	}
+	return values, scanner.Err()
+}
+
+func include(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
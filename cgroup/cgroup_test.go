@@ -0,0 +1,143 @@
+package cgroup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robinmonjo/dock/procfs"
+)
+
+func openFixture(t *testing.T, version string) *Cgroup {
+	root := "./assets/" + version
+	cg, err := Open(procfs.NewFS(root+"/proc").Proc(1), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cg
+}
+
+func TestOpenV1(t *testing.T) {
+	t.Parallel()
+	cg := openFixture(t, "v1")
+	if cg.Version != 1 {
+		t.Fatalf("expected version 1, got %d", cg.Version)
+	}
+
+	mem, err := cg.Memory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMem := MemoryStats{Usage: 52428800, MaxUsage: 67108864, Limit: 536870912}
+	if *mem != expectedMem {
+		t.Fatalf("expected memory stats %#v, got %#v", expectedMem, *mem)
+	}
+
+	events, err := cg.MemoryEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events.OOMKill != 2 {
+		t.Fatalf("expected 2 oom kills, got %d", events.OOMKill)
+	}
+
+	cpu, err := cg.CPU()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCPU := CPUStats{Usage: 93 * time.Second, Periods: 1200, ThrottledPeriods: 37, ThrottledTime: 2500 * time.Millisecond}
+	if *cpu != expectedCPU {
+		t.Fatalf("expected cpu stats %#v, got %#v", expectedCPU, *cpu)
+	}
+
+	pids, err := cg.Pids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPids := PidsStats{Current: 12, Limit: 0}
+	if *pids != expectedPids {
+		t.Fatalf("expected pids stats %#v, got %#v", expectedPids, *pids)
+	}
+}
+
+func TestOpenV2(t *testing.T) {
+	t.Parallel()
+	cg := openFixture(t, "v2")
+	if cg.Version != 2 {
+		t.Fatalf("expected version 2, got %d", cg.Version)
+	}
+
+	mem, err := cg.Memory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMem := MemoryStats{Usage: 104857600, Limit: 268435456}
+	if *mem != expectedMem {
+		t.Fatalf("expected memory stats %#v, got %#v", expectedMem, *mem)
+	}
+
+	events, err := cg.MemoryEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedEvents := MemoryEvents{Max: 14, OOM: 3, OOMKill: 1}
+	if *events != expectedEvents {
+		t.Fatalf("expected memory events %#v, got %#v", expectedEvents, *events)
+	}
+
+	cpu, err := cg.CPU()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCPU := CPUStats{Usage: 4200 * time.Millisecond, Periods: 500, ThrottledPeriods: 20, ThrottledTime: 800 * time.Millisecond}
+	if *cpu != expectedCPU {
+		t.Fatalf("expected cpu stats %#v, got %#v", expectedCPU, *cpu)
+	}
+
+	pids, err := cg.Pids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPids := PidsStats{Current: 7, Limit: 100}
+	if *pids != expectedPids {
+		t.Fatalf("expected pids stats %#v, got %#v", expectedPids, *pids)
+	}
+}
+
+func TestCgroupDir(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		mountRoot string
+		path      string
+		expected  string
+	}{
+		{"/", "/docker/abc", "/sys/fs/cgroup/memory/docker/abc"},                 //host view
+		{"/docker/abc", "/docker/abc", "/sys/fs/cgroup/memory"},                  //container view, no cgroup namespace
+		{"/docker/abc", "/docker/abc/sub", "/sys/fs/cgroup/memory/sub"},          //nested cgroup
+		{"/", "/", "/sys/fs/cgroup/memory"},                                      //cgroup namespace
+		{"/docker/abc", "/docker/abcdef", "/sys/fs/cgroup/memory/docker/abcdef"}, //prefix but not parent
+	}
+	for _, test := range tests {
+		m := &procfs.Mount{Root: test.mountRoot, MountPoint: "/sys/fs/cgroup/memory"}
+		if dir := cgroupDir("/", m, test.path); dir != test.expected {
+			t.Fatalf("expected %q for path %q mounted from %q, got %q", test.expected, test.path, test.mountRoot, dir)
+		}
+	}
+}
+
+func TestContainerID(t *testing.T) {
+	t.Parallel()
+	expected := map[string]string{
+		"v1": "4a7b5c0e7e3f9d2c1b8a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c", //from cgroup paths
+		"v2": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", //from bind mounts
+	}
+	for version, id := range expected {
+		p := procfs.NewFS("./assets/" + version + "/proc").Proc(1)
+		if got := ContainerID(p); got != id {
+			t.Fatalf("%s: expected container id %q, got %q", version, id, got)
+		}
+	}
+
+	if got := lastMatch("/docker/" + expected["v1"] + "/docker/" + expected["v2"]); got != expected["v2"] {
+		t.Fatalf("expected innermost container id %q, got %q", expected["v2"], got)
+	}
+}
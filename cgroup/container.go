@@ -0,0 +1,43 @@
+package cgroup
+
+import (
+	"regexp"
+
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// container runtimes (docker, containerd, cri-o ...) name cgroups and state directories after
+// the 64 hexadecimal characters container ID
+var containerIDRegexp = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// ContainerID returns the ID of the container the given process runs in, or "" if it can't be
+// found. The ID is looked up in the process cgroup paths (i.e. /docker/<id>). With cgroup
+// namespaces, paths are relative to the container cgroup ("/"), so the sources of the files
+// bind mounted by the runtime (i.e. /var/lib/docker/containers/<id>/hostname) are used instead
+func ContainerID(p *procfs.Proc) string {
+	if cgroups, err := p.Cgroups(); err == nil {
+		for _, cg := range cgroups {
+			if id := lastMatch(cg.Path); id != "" {
+				return id
+			}
+		}
+	}
+
+	if mounts, err := p.MountInfo(); err == nil {
+		for _, m := range mounts {
+			if id := lastMatch(m.Root); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// nested cgroups (i.e. docker in docker) end with the innermost container ID
+func lastMatch(path string) string {
+	matches := containerIDRegexp.FindAllString(path, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1]
+}
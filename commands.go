@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/robinmonjo/dock/control"
+	"github.com/robinmonjo/dock/notifier"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	waitInterval       = 200 * time.Millisecond
+)
+
+var socketFlag = cli.StringFlag{Name: "socket, s", Value: control.DefaultSocket, Usage: "control socket of the running dock (see --control-socket)", EnvVar: "DOCK_CONTROL_SOCKET"}
+
+// subcommands act on a running dock through its control socket, but config. Their names are
+// reserved: a command with one of them must be given with its path (i.e. dock ./status)
+var commands = []cli.Command{
+	{
+		Name:   "status",
+		Usage:  "print the state of the process, exits with 1 unless it is ready",
+		Flags:  []cli.Flag{socketFlag, cli.BoolFlag{Name: "json", Usage: "print the status as JSON"}},
+		Action: clientAction(statusCommand),
+	},
+	{
+		Name:   "signal",
+		Usage:  "send a signal to the process (i.e. dock signal HUP)",
+		Flags:  []cli.Flag{socketFlag},
+		Action: clientAction(signalCommand),
+	},
+	{
+		Name:   "restart",
+		Usage:  "restart the process",
+		Flags:  []cli.Flag{socketFlag},
+		Action: clientAction(func(c *cli.Context, client *control.Client) error { return client.Restart() }),
+	},
+	{
+		Name:   "wait",
+		Usage:  "wait for the control socket to answer, or for the process to be ready with --ready",
+		Flags:  []cli.Flag{socketFlag, cli.BoolFlag{Name: "ready", Usage: "wait for the process to be ready"}, cli.StringFlag{Name: "timeout", Value: defaultWaitTimeout.String(), Usage: "maximum time to wait"}},
+		Action: clientAction(waitCommand),
+	},
+	{
+		Name:  "config",
+		Usage: "manage the config file (see --config)",
+		Subcommands: []cli.Command{
+			{
+				Name:   "validate",
+				Usage:  "check a config file (--config by default), exits with 1 and reports each error otherwise",
+				Action: validateConfigCommand,
+			},
+		},
+	},
+}
+
+// run a subcommand and exit, with status 1 on error
+func clientAction(f func(c *cli.Context, client *control.Client) error) func(c *cli.Context) {
+	return func(c *cli.Context) {
+		if err := f(c, control.NewClient(c.String("socket"))); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func statusCommand(c *cli.Context, client *control.Client) error {
+	s, err := client.Status()
+	if err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		if err := json.NewEncoder(os.Stdout).Encode(s); err != nil {
+			return err
+		}
+	} else {
+		printStatus(os.Stdout, s)
+	}
+	if s.State != notifier.StatusReady {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func signalCommand(c *cli.Context, client *control.Client) error {
+	if len(c.Args()) != 1 {
+		return fmt.Errorf("expected a signal name or number, i.e. dock signal HUP")
+	}
+	return client.Signal(c.Args()[0])
+}
+
+func waitCommand(c *cli.Context, client *control.Client) error {
+	timeout, err := time.ParseDuration(c.String("timeout"))
+	if err != nil {
+		return err
+	}
+	_, err = client.Wait(timeout, waitInterval, func(s *control.Status) bool {
+		return !c.Bool("ready") || s.State == notifier.StatusReady
+	})
+	return err
+}
+
+func printStatus(w io.Writer, s *control.Status) {
+	fmt.Fprintf(w, "state:    %s\n", s.State)
+	if s.Pid != 0 {
+		fmt.Fprintf(w, "pid:      %d\n", s.Pid)
+		fmt.Fprintf(w, "uptime:   %v\n", time.Duration(int64(s.Uptime))*time.Second)
+	}
+	fmt.Fprintf(w, "restarts: %d\n", s.Restarts)
+	fmt.Fprintf(w, "command:  %s\n", strings.Join(s.Argv, " "))
+	if len(s.Tree) > 0 {
+		fmt.Fprintln(w, "tree:")
+		printTree(w, s.Tree, "  ")
+	}
+}
+
+func printTree(w io.Writer, tree []*control.Process, indent string) {
+	for _, p := range tree {
+		command := p.Name
+		if len(p.Argv) > 0 {
+			command = strings.Join(p.Argv, " ")
+		}
+		fmt.Fprintf(w, "%s%d %s (%s, rss %d kB)\n", indent, p.Pid, command, p.State, p.RSS/1024)
+		printTree(w, p.Children, indent+"  ")
+	}
+}
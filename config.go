@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/robinmonjo/dock/config"
+)
+
+// every option may be set with an environment variable named after its flag, i.e. DOCK_WEB_HOOK
+// for --web-hook, and in the --config file. Precedence is flag > env > file > default
+func envVar(name string) string {
+	return "DOCK_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// name of a flag without its aliases (i.e. debug for "debug, d")
+func flagName(f cli.Flag) string {
+	return strings.TrimSpace(strings.Split(flagNames(f), ",")[0])
+}
+
+func flagNames(f cli.Flag) string {
+	switch f := f.(type) {
+	case cli.StringFlag:
+		return f.Name
+	case cli.BoolFlag:
+		return f.Name
+	case cli.BoolTFlag:
+		return f.Name
+	case cli.IntFlag:
+		return f.Name
+	case cli.StringSliceFlag:
+		return f.Name
+	}
+	panic(fmt.Sprintf("unsupported flag %T", f))
+}
+
+// set the environment variable of flags that don't have one. Repeatable flags are left out, cli
+// would split their variable on commas (which web hook specs contain), configure reads it instead
+func withEnv(flags []cli.Flag) []cli.Flag {
+	withEnv := make([]cli.Flag, len(flags))
+	for i, f := range flags {
+		env := envVar(flagName(f))
+		switch f := f.(type) {
+		case cli.StringFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = env
+			}
+			withEnv[i] = f
+		case cli.BoolFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = env
+			}
+			withEnv[i] = f
+		case cli.IntFlag:
+			if f.EnvVar == "" {
+				f.EnvVar = env
+			}
+			withEnv[i] = f
+		default:
+			withEnv[i] = f
+		}
+	}
+	return withEnv
+}
+
+// options of the config file, set once the flags of the app are known
+var configOptions []config.Option
+
+// options that may be set in a config file, all flags but --config itself
+func fileOptions(flags []cli.Flag) []config.Option {
+	options := []config.Option{}
+	for _, f := range flags {
+		o := config.Option{Name: flagName(f)}
+		switch f.(type) {
+		case cli.BoolFlag, cli.BoolTFlag:
+			o.Kind = config.Bool
+		case cli.IntFlag:
+			o.Kind = config.Int
+		case cli.StringSliceFlag:
+			o.Kind = config.StringSlice
+		}
+		if o.Name != "config" {
+			options = append(options, o)
+		}
+	}
+	return options
+}
+
+// environment variables of repeatable flags hold a value per line, blank lines are ignored
+func splitLines(env string) []string {
+	values := []string{}
+	for _, line := range strings.Split(env, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			values = append(values, line)
+		}
+	}
+	return values
+}
+
+// configure sets the default values of the flags that aren't given on the command line: the
+// environment variable of repeatable flags, then the values of the --config file for flags whose
+// variable isn't set either. Other flags must have their environment variable set (see withEnv).
+// Nothing is done when a subcommand is run
+func configure(app *cli.App, args []string) error {
+	// parse the command line beforehand to know which flags are given
+	set := flag.NewFlagSet(app.Name, flag.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	names := map[string]string{} //alias -> flag name
+	for _, f := range app.Flags {
+		for _, alias := range strings.Split(flagNames(f), ",") {
+			alias = strings.TrimSpace(alias)
+			names[alias] = flagName(f)
+			switch f.(type) {
+			case cli.BoolFlag, cli.BoolTFlag:
+				set.Bool(alias, false, "")
+			default:
+				set.String(alias, "", "")
+			}
+		}
+	}
+	if err := set.Parse(args); err != nil {
+		return nil //reported when the app runs
+	}
+	if set.NArg() > 0 {
+		for _, c := range app.Commands {
+			if set.Arg(0) == c.Name || set.Arg(0) == c.ShortName {
+				return nil
+			}
+		}
+	}
+	given := map[string]bool{}
+	set.Visit(func(f *flag.Flag) {
+		given[names[f.Name]] = true
+	})
+
+	path := set.Lookup("config").Value.String()
+	if path == "" {
+		path = os.Getenv(envVar("config"))
+	}
+	values := config.Values{}
+	if path != "" {
+		var err error
+		if values, err = config.Load(path, configOptions); err != nil {
+			return err
+		}
+	}
+
+	for i, f := range app.Flags {
+		name := flagName(f)
+		if given[name] {
+			// values given on the command line would be appended to the default ones
+			continue
+		}
+		v, ok := values[name]
+		switch f := f.(type) {
+		case cli.StringSliceFlag:
+			if env := os.Getenv(envVar(name)); env != "" {
+				s := cli.StringSlice(splitLines(env))
+				f.Value = &s
+			} else if ok {
+				s := cli.StringSlice(v.([]string))
+				f.Value = &s
+			}
+			app.Flags[i] = f
+		case cli.StringFlag:
+			if ok && os.Getenv(f.EnvVar) == "" {
+				f.Value = v.(string)
+			}
+			app.Flags[i] = f
+		case cli.IntFlag:
+			if ok && os.Getenv(f.EnvVar) == "" {
+				f.Value = v.(int)
+			}
+			app.Flags[i] = f
+		case cli.BoolFlag:
+			if ok && os.Getenv(f.EnvVar) == "" && v.(bool) {
+				app.Flags[i] = cli.BoolTFlag{Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar}
+			}
+		}
+	}
+	return nil
+}
+
+// dock config validate [file], the file is --config (or $DOCK_CONFIG) by default
+func validateConfigCommand(c *cli.Context) {
+	path := c.Args().First()
+	if path == "" {
+		path = c.GlobalString("config")
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "no config file given")
+		os.Exit(1)
+	}
+
+	if _, err := config.Load(path, configOptions); err != nil {
+		if list, ok := err.(config.ErrorList); ok {
+			for _, e := range list {
+				fmt.Fprintln(os.Stderr, e)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("%s: ok\n", path)
+}
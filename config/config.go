@@ -0,0 +1,269 @@
+// Package config reads options from a JSON or TOML file. Options are top level keys, named
+// after the command line flags, i.e. {"web-hook": ["https://example.com/hook"], "thug": true}
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind of value an option holds
+type Kind int
+
+const (
+	String      Kind = iota //JSON or TOML string
+	Bool                    //true or false
+	Int                     //integer
+	StringSlice             //array of strings, for repeatable options
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Bool:
+		return "a boolean"
+	case Int:
+		return "an integer"
+	case StringSlice:
+		return "an array of strings"
+	}
+	return "a string"
+}
+
+// Option describes an option that may be set in a file
+type Option struct {
+	Name string
+	Kind Kind
+}
+
+// Values read from a file by option name, they are a string, bool, int or []string depending
+// on the kind of the option
+type Values map[string]interface{}
+
+// Error locates a problem in a file, line and column are 0 when unknown
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			pos += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return pos + ": " + e.Msg
+}
+
+// ErrorList holds every problem found in a file, sorted by position
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l ErrorList) Less(i, j int) bool {
+	if l[i].Line != l[j].Line {
+		return l[i].Line < l[j].Line
+	}
+	return l[i].Column < l[j].Column
+}
+
+// Load reads the options of a file, TOML if its extension is .toml, JSON otherwise. Syntax
+// errors, unknown options and values of the wrong kind are returned as an ErrorList
+func Load(path string, options []Option) (Values, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		return ParseTOML(path, data, options)
+	}
+	return ParseJSON(path, data, options)
+}
+
+// ParseJSON reads the options of a JSON object, file only names the source in errors
+func ParseJSON(file string, data []byte, options []Option) (Values, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var raw interface{}
+	if err := d.Decode(&raw); err != nil {
+		e := &Error{File: file, Msg: err.Error()}
+		if serr, ok := err.(*json.SyntaxError); ok && serr.Offset > 0 {
+			e.Line, e.Column = position(data, int(serr.Offset)-1) //offset is after the invalid byte
+		}
+		return nil, ErrorList{e}
+	}
+	if _, ok := raw.(map[string]interface{}); !ok {
+		return nil, ErrorList{{File: file, Line: 1, Msg: "expected a JSON object of options"}}
+	}
+
+	// the object is valid, walk its keys in order since decoding it in a map hides the
+	// duplicated ones
+	p := newParser(file, options)
+	d = json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	d.Token() // {
+	occurrences := map[string]int{}
+	for d.More() {
+		t, _ := d.Token()
+		key := t.(string)
+		var v interface{}
+		d.Decode(&v)
+
+		line, column := 0, 0
+		locs := regexp.MustCompile(`"`+regexp.QuoteMeta(key)+`"\s*:`).FindAllIndex(data, -1)
+		if n := occurrences[key]; n < len(locs) {
+			line, column = position(data, locs[n][0])
+		}
+		occurrences[key]++
+		p.set(key, jsonValue(v), line, column)
+	}
+	return p.result()
+}
+
+// values decoded by encoding/json converted to the ones of the TOML parser
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, elem := range v {
+			a[i] = jsonValue(elem)
+		}
+		return a
+	}
+	return v
+}
+
+// line and column (1 based) of an offset
+func position(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndex(data[:offset], []byte("\n"))
+	return line, column
+}
+
+func index(options []Option) map[string]Kind {
+	kinds := map[string]Kind{}
+	for _, o := range options {
+		kinds[o.Name] = o.Kind
+	}
+	return kinds
+}
+
+// parser checks the values of a file against the options, it's shared by both formats
+type parser struct {
+	file    string
+	options map[string]Kind
+	seen    map[string]bool
+	values  Values
+	errors  ErrorList
+}
+
+func newParser(file string, options []Option) *parser {
+	return &parser{file: file, options: index(options), seen: map[string]bool{}, values: Values{}}
+}
+
+func (p *parser) errorf(line, column int, format string, args ...interface{}) {
+	p.errors = append(p.errors, &Error{File: p.file, Line: line, Column: column, Msg: fmt.Sprintf(format, args...)})
+}
+
+// set validates the value of an option. v is a string, bool, int64, float64 or []interface{}
+func (p *parser) set(key string, v interface{}, line, column int) {
+	kind, ok := p.options[key]
+	if !ok {
+		p.errorf(line, column, "unknown option %q", key)
+		return
+	}
+	if p.seen[key] {
+		p.errorf(line, column, "option %q set twice", key)
+		return
+	}
+	p.seen[key] = true
+
+	var value interface{}
+	switch kind {
+	case String:
+		value, ok = v.(string)
+	case Bool:
+		value, ok = v.(bool)
+	case Int:
+		var i int64
+		if i, ok = v.(int64); ok {
+			value = int(i)
+		}
+	case StringSlice:
+		var a []interface{}
+		if a, ok = v.([]interface{}); ok {
+			s := make([]string, len(a))
+			for i, elem := range a {
+				if s[i], ok = elem.(string); !ok {
+					break
+				}
+			}
+			value = s
+		}
+	}
+	if !ok {
+		p.errorf(line, column, "option %q must be %s, got %s", key, kind, describe(v))
+		return
+	}
+	p.values[key] = value
+}
+
+func (p *parser) result() (Values, error) {
+	if len(p.errors) > 0 {
+		sort.Stable(p.errors)
+		return nil, p.errors
+	}
+	return p.values, nil
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("the string %q", v)
+	case bool:
+		return fmt.Sprintf("the boolean %v", v)
+	case int64:
+		return fmt.Sprintf("the integer %d", v)
+	case float64:
+		return fmt.Sprintf("the number %v", v)
+	case []interface{}:
+		for _, elem := range v {
+			if _, ok := elem.(string); !ok {
+				return "an array containing " + describe(elem)
+			}
+		}
+		return "an array"
+	case nil:
+		return "null"
+	}
+	return "an object"
+}
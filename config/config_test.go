@@ -0,0 +1,145 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var options = []Option{
+	{Name: "io", Kind: String},
+	{Name: "thug", Kind: Bool},
+	{Name: "log-rotate", Kind: Int},
+	{Name: "web-hook", Kind: StringSlice},
+}
+
+var expected = Values{
+	"io":         "file:///var/log/app.log",
+	"thug":       true,
+	"log-rotate": 24,
+	"web-hook":   []string{"https://example.com/hook;events=ready,exited", "file:///var/log/hook.jsonl"},
+}
+
+func TestParseJSON(t *testing.T) {
+	data := `{
+  "io": "file:///var/log/app.log",
+  "thug": true,
+  "log-rotate": 24,
+  "web-hook": ["https://example.com/hook;events=ready,exited", "file:///var/log/hook.jsonl"]
+}`
+	values, err := ParseJSON("dock.json", []byte(data), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	data := `# dock options
+io = "file:///var/log/app.log"
+thug = true # translate signals
+log-rotate = 2_4
+web-hook = [
+  "https://example.com/hook;events=ready,exited",
+  'file:///var/log/hook.jsonl', # trailing comma
+]
+`
+	values, err := ParseTOML("dock.toml", []byte(data), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+
+	values, err = ParseTOML("dock.toml", []byte(`"io" = "tab\there é"`), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["io"] != "tab\there é" {
+		t.Fatalf("unexpected value %q", values["io"])
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		parse func(file string, data []byte, options []Option) (Values, error)
+		data  string
+		errs  []string
+	}{
+		{ParseJSON, "{\n  \"io\": \"stdout\",\n  \"thgu\": true,\n  \"log-rotate\": \"24\"\n}", []string{
+			`dock.conf:3:3: unknown option "thgu"`,
+			`dock.conf:4:3: option "log-rotate" must be an integer, got the string "24"`,
+		}},
+		{ParseJSON, "{\"web-hook\": [\"a\", 1]}", []string{
+			`dock.conf:1:2: option "web-hook" must be an array of strings, got an array containing the integer 1`,
+		}},
+		{ParseJSON, "{\n  \"io\": \"stdout\",\n}", []string{
+			`dock.conf:3:1: invalid character '}' looking for beginning of object key string`,
+		}},
+		{ParseJSON, "{\n  \"io\": \"stdout\",\n  \"io\": \"file:///var/log/app.log\"\n}", []string{
+			`dock.conf:3:3: option "io" set twice`,
+		}},
+		{ParseJSON, `["io"]`, []string{`dock.conf:1: expected a JSON object of options`}},
+		{ParseTOML, "thug = 1\nio = stdout\nlog-rotate = 3", []string{
+			`dock.conf:1:1: option "thug" must be a boolean, got the integer 1`,
+			`dock.conf:2:6: invalid value "stdout" (strings must be quoted)`,
+		}},
+		{ParseTOML, "log-rotate = 1.5\nlog-rotate = 2", []string{
+			`dock.conf:1:1: option "log-rotate" must be an integer, got the number 1.5`,
+			`dock.conf:2:1: option "log-rotate" set twice`,
+		}},
+		{ParseTOML, "io = \"stdout", []string{`dock.conf:1:6: unterminated string`}},
+		{ParseTOML, "[dock]\nio = \"stdout\"", []string{`dock.conf:1:1: tables are not supported, options are top level keys`}},
+		{ParseTOML, "io \"stdout\"", []string{`dock.conf:1:4: expected = after key "io"`}},
+		{ParseTOML, "web-hook = [\"a\" \"b\"]", []string{`dock.conf:1:17: expected , or ] in array`}},
+	}
+
+	for _, test := range tests {
+		_, err := test.parse("dock.conf", []byte(test.data), options)
+		list, ok := err.(ErrorList)
+		if !ok {
+			t.Fatalf("%q: expected an error list, got %v", test.data, err)
+		}
+		errs := []string{}
+		for _, e := range list {
+			errs = append(errs, e.Error())
+		}
+		if !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%q: expected errors %q, got %q", test.data, test.errs, errs)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, data := range map[string]string{
+		"dock.json": `{"io": "stdout", "thug": true}`,
+		"dock.toml": "io = 'stdout'\nthug = true",
+	} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		values, err := Load(path, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values["io"] != "stdout" || values["thug"] != true {
+			t.Fatalf("%s: unexpected values %v", name, values)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.json"), options); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+}
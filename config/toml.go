@@ -0,0 +1,264 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseTOML reads the options of a TOML document, file only names the source in errors. Options
+// being flat, only the subset of TOML they need is supported: top level keys, strings, booleans,
+// integers and arrays (that may span lines). Tables, floats and dates are rejected
+func ParseTOML(file string, data []byte, options []Option) (Values, error) {
+	p := newParser(file, options)
+	s := &tomlScanner{file: file, data: string(data), line: 1, column: 1}
+	if err := s.document(p); err != nil {
+		// syntax errors stop the parsing, they come after the errors of the previous lines
+		p.errors = append(p.errors, err)
+	}
+	return p.result()
+}
+
+type tomlScanner struct {
+	file         string
+	data         string
+	pos          int
+	line, column int
+}
+
+// syntax error at the current position
+func (s *tomlScanner) errorf(format string, args ...interface{}) *Error {
+	return s.errorAt(s.line, s.column, format, args...)
+}
+
+func (s *tomlScanner) errorAt(line, column int, format string, args ...interface{}) *Error {
+	return &Error{File: s.file, Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (s *tomlScanner) eof() bool {
+	return s.pos >= len(s.data)
+}
+
+func (s *tomlScanner) peek() byte {
+	if s.eof() {
+		return 0
+	}
+	return s.data[s.pos]
+}
+
+func (s *tomlScanner) next() byte {
+	c := s.data[s.pos]
+	s.pos++
+	if c == '\n' {
+		s.line++
+		s.column = 1
+	} else if utf8.RuneStart(c) {
+		s.column++
+	}
+	return c
+}
+
+// skip spaces and a comment, and new lines if multiline
+func (s *tomlScanner) skip(multiline bool) {
+	for !s.eof() {
+		switch c := s.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			s.next()
+		case c == '\n' && multiline:
+			s.next()
+		case c == '#':
+			for !s.eof() && s.peek() != '\n' {
+				s.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *tomlScanner) document(p *parser) *Error {
+	for {
+		s.skip(true)
+		if s.eof() {
+			return nil
+		}
+		if s.peek() == '[' {
+			return s.errorf("tables are not supported, options are top level keys")
+		}
+
+		line, column := s.line, s.column
+		key, err := s.key()
+		if err != nil {
+			return err
+		}
+		s.skip(false)
+		if s.peek() == '.' {
+			return s.errorf("dotted keys are not supported, options are top level keys")
+		}
+		if s.peek() != '=' {
+			return s.errorf("expected = after key %q", key)
+		}
+		s.next()
+		s.skip(false)
+		v, err := s.value()
+		if err != nil {
+			return err
+		}
+		s.skip(false)
+		if !s.eof() && s.peek() != '\n' {
+			return s.errorf("expected a new line after the value of %q", key)
+		}
+		p.set(key, v, line, column)
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+func (s *tomlScanner) key() (string, *Error) {
+	switch s.peek() {
+	case '"':
+		return s.basicString()
+	case '\'':
+		return s.literalString()
+	}
+	start := s.pos
+	for !s.eof() && isBareKeyChar(s.peek()) {
+		s.next()
+	}
+	if s.pos == start {
+		return "", s.errorf("expected a key, got %q", s.peek())
+	}
+	return s.data[start:s.pos], nil
+}
+
+// value returns a string, bool, int64, float64 or []interface{}
+func (s *tomlScanner) value() (interface{}, *Error) {
+	switch c := s.peek(); {
+	case s.eof() || c == '\n' || c == '#':
+		return nil, s.errorf("expected a value")
+	case c == '"':
+		if strings.HasPrefix(s.data[s.pos:], `"""`) {
+			return nil, s.errorf("multi-line strings are not supported")
+		}
+		return s.basicString()
+	case c == '\'':
+		if strings.HasPrefix(s.data[s.pos:], `'''`) {
+			return nil, s.errorf("multi-line strings are not supported")
+		}
+		return s.literalString()
+	case c == '[':
+		return s.array()
+	case c == '{':
+		return nil, s.errorf("inline tables are not supported")
+	}
+
+	start, line, column := s.pos, s.line, s.column
+	for !s.eof() && strings.IndexByte("+-_.:0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", s.peek()) != -1 {
+		s.next()
+	}
+	token := s.data[start:s.pos]
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	number := strings.Replace(token, "_", "", -1)
+	if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	if token == "" {
+		return nil, s.errorf("invalid value starting with %q", s.peek())
+	}
+	return nil, s.errorAt(line, column, "invalid value %q (strings must be quoted)", token)
+}
+
+func (s *tomlScanner) array() (interface{}, *Error) {
+	s.next() // [
+	a := []interface{}{}
+	for {
+		s.skip(true)
+		if s.peek() == ']' {
+			s.next()
+			return a, nil
+		}
+		v, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		s.skip(true)
+		switch {
+		case s.peek() == ',':
+			s.next()
+		case s.peek() == ']':
+		default:
+			return nil, s.errorf("expected , or ] in array")
+		}
+	}
+}
+
+var escapes = map[byte]string{'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': "\"", '\\': "\\"}
+
+func (s *tomlScanner) basicString() (string, *Error) {
+	line, column := s.line, s.column
+	s.next() // "
+	var b bytes.Buffer
+	for {
+		if s.eof() || s.peek() == '\n' {
+			return "", s.errorAt(line, column, "unterminated string")
+		}
+		c := s.next()
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if s.eof() {
+				return "", s.errorAt(line, column, "unterminated string")
+			}
+			e := s.next()
+			if r, ok := escapes[e]; ok {
+				b.WriteString(r)
+				continue
+			}
+			size := 4
+			if e == 'U' {
+				size = 8
+			}
+			if e != 'u' && e != 'U' || s.pos+size > len(s.data) {
+				return "", s.errorf("invalid escape sequence \\%c", e)
+			}
+			code, err := strconv.ParseUint(s.data[s.pos:s.pos+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", s.errorf("invalid escape sequence \\%c%s", e, s.data[s.pos:s.pos+size])
+			}
+			for i := 0; i < size; i++ {
+				s.next()
+			}
+			b.WriteRune(rune(code))
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (s *tomlScanner) literalString() (string, *Error) {
+	line, column := s.line, s.column
+	s.next() // '
+	start := s.pos
+	for {
+		if s.eof() || s.peek() == '\n' {
+			return "", s.errorAt(line, column, "unterminated string")
+		}
+		if s.next() == '\'' {
+			return s.data[start : s.pos-1], nil
+		}
+	}
+}
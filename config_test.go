@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codegangsta/cli"
+	"github.com/robinmonjo/dock/config"
+)
+
+func testApp() *cli.App {
+	app := cli.NewApp()
+	app.Flags = withEnv([]cli.Flag{
+		cli.StringFlag{Name: "config"},
+		cli.StringFlag{Name: "io", Value: "stdout"},
+		cli.BoolFlag{Name: "thug"},
+		cli.IntFlag{Name: "log-rotate"},
+		cli.StringSliceFlag{Name: "web-hook", Value: &cli.StringSlice{}},
+	})
+	return app
+}
+
+func TestConfigure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "dock.json")
+	data := `{"io": "file:///var/log/file.log", "thug": true, "log-rotate": 24, "web-hook": ["file://file.jsonl"]}`
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(options []config.Option) { configOptions = options }(configOptions)
+	configOptions = fileOptions(testApp().Flags)
+
+	// precedence is flag > env > file > default, for each kind of flag
+	tests := []struct {
+		flag     string
+		config   bool //run with --config
+		env      string
+		args     []string
+		expected interface{}
+	}{
+		{"io", false, "", nil, "stdout"},
+		{"io", true, "", nil, "file:///var/log/file.log"},
+		{"io", true, "file:///var/log/env.log", nil, "file:///var/log/env.log"},
+		{"io", true, "file:///var/log/env.log", []string{"--io", "file:///var/log/flag.log"}, "file:///var/log/flag.log"},
+
+		{"thug", false, "", nil, false},
+		{"thug", true, "", nil, true},
+		{"thug", true, "false", nil, false},
+		{"thug", true, "", []string{"--thug=false"}, false},
+		{"thug", false, "", []string{"--thug"}, true},
+
+		{"log-rotate", false, "", nil, 0},
+		{"log-rotate", true, "", nil, 24},
+		{"log-rotate", true, "12", nil, 12},
+		{"log-rotate", true, "12", []string{"--log-rotate", "6"}, 6},
+
+		{"web-hook", false, "", nil, []string{}},
+		{"web-hook", true, "", nil, []string{"file://file.jsonl"}},
+		{"web-hook", true, "file://a.jsonl\n file://b.jsonl;events=ready,exited\n", nil, []string{"file://a.jsonl", "file://b.jsonl;events=ready,exited"}},
+		{"web-hook", false, "file://a.jsonl;events=ready,exited", nil, []string{"file://a.jsonl;events=ready,exited"}},
+		{"web-hook", true, "file://a.jsonl", []string{"--web-hook", "file://c.jsonl", "--web-hook", "file://d.jsonl"}, []string{"file://c.jsonl", "file://d.jsonl"}},
+	}
+
+	for _, test := range tests {
+		args := test.args
+		if test.config {
+			args = append([]string{"--config", file}, args...)
+		}
+		args = append(args, "true")
+		if test.env != "" {
+			os.Setenv(envVar(test.flag), test.env)
+		}
+
+		var value interface{}
+		app := testApp()
+		app.Action = func(c *cli.Context) {
+			switch test.expected.(type) {
+			case string:
+				value = c.String(test.flag)
+			case bool:
+				value = c.Bool(test.flag)
+			case int:
+				value = c.Int(test.flag)
+			case []string:
+				value = c.StringSlice(test.flag)
+				if value == nil {
+					value = []string{}
+				}
+			}
+		}
+		err := configure(app, args)
+		if err == nil {
+			err = app.Run(append([]string{"dock"}, args...))
+		}
+		os.Unsetenv(envVar(test.flag))
+		if err != nil {
+			t.Fatalf("%v (env %q): %v", args, test.env, err)
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%v (env %q): expected %s to be %v, got %v", args, test.env, test.flag, test.expected, value)
+		}
+	}
+}
+
+func TestConfigureInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "dock.toml")
+	if err := ioutil.WriteFile(file, []byte("log-rotate = \"24\""), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(options []config.Option) { configOptions = options }(configOptions)
+	configOptions = fileOptions(testApp().Flags)
+
+	if err := configure(testApp(), []string{"--config", file, "true"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	// subcommands don't load the file
+	if err := configure(&cli.App{Flags: testApp().Flags, Commands: commands}, []string{"--config", file, "status"}); err != nil {
+		t.Fatal(err)
+	}
+}
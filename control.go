@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/robinmonjo/dock/control"
+	"github.com/robinmonjo/dock/logrotate"
+	"github.com/robinmonjo/dock/notifier"
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// supervisor exposes the process through the control socket (see --control-socket)
+type supervisor struct {
+	process *process
+	rotator *logrotate.Rotator //nil if the process output isn't a file
+}
+
+func (s *supervisor) Status() (*control.Status, error) {
+	ps := &notifier.Ps{}
+	s.process.describe(ps)
+
+	tree, err := control.Tree(procfs.Self())
+	if err != nil {
+		return nil, err
+	}
+	return &control.Status{
+		State:     lifecycle.State(),
+		Pid:       s.process.runningPid(),
+		Argv:      ps.Argv,
+		StartedAt: ps.StartedAt,
+		Uptime:    s.process.uptime().Seconds(),
+		Restarts:  ps.Restarts,
+		DockPid:   os.Getpid(),
+		Tree:      tree,
+	}, nil
+}
+
+func (s *supervisor) Signal(name string) error {
+	sig, err := parseSignal(name)
+	if err != nil {
+		return control.BadRequest("%v", err)
+	}
+	controlLog.WithField("signal", signalName(sig)).Debug("sending signal")
+	return controlError(s.process.signalRunning(0, sig))
+}
+
+func (s *supervisor) Restart() error {
+	controlLog.Debug("restarting the process")
+	return controlError(s.process.restartRunning(0))
+}
+
+func (s *supervisor) Rotate() error {
+	if s.rotator == nil {
+		return control.Conflict("log rotation requires --io to be a file")
+	}
+	archive, err := s.rotator.Rotate()
+	if err != nil {
+		return err
+	}
+	controlLog.Debugf("logs rotated to %s", archive)
+	return nil
+}
+
+// send a SIGTERM, and a SIGKILL if the process is still running after killTimeout
+func (s *supervisor) Stop() error {
+	pid := s.process.runningPid()
+	if pid == 0 {
+		return controlError(errNotRunning)
+	}
+	// stopping before the signal, a process exiting right away would be killed already
+	processStateChanged(&notifier.Ps{Status: notifier.StatusStopping, Message: "stop requested"})
+	if err := s.process.stopRunning(); err != nil {
+		return controlError(err)
+	}
+
+	time.AfterFunc(killTimeout*time.Second, func() {
+		if err := s.process.signalRunning(pid, syscall.SIGKILL); err == nil {
+			controlLog.WithField("pid", pid).Debugf("process killed after %ds", killTimeout)
+		}
+	})
+	return nil
+}
+
+// errors caused by the process state are conflicts
+func controlError(err error) error {
+	if err == errNotRunning || err == errStopping {
+		return control.Conflict("%v", err)
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const requestTimeout = 10 * time.Second
+
+// Client calls the control API of a running dock
+type Client struct {
+	Path string
+	http *http.Client
+}
+
+func NewClient(path string) *Client {
+	return &Client{
+		Path: path,
+		http: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) {
+					return net.DialTimeout("unix", path, requestTimeout)
+				},
+			},
+		},
+	}
+}
+
+func (c *Client) Status() (*Status, error) {
+	resp, err := c.http.Get("http://dock/status")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+	var s Status
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *Client) Signal(name string) error {
+	body, err := json.Marshal(map[string]string{"signal": name})
+	if err != nil {
+		return err
+	}
+	return c.post("/signal", body)
+}
+
+func (c *Client) Restart() error {
+	return c.post("/restart", nil)
+}
+
+func (c *Client) Rotate() error {
+	return c.post("/rotate", nil)
+}
+
+func (c *Client) Stop() error {
+	return c.post("/stop", nil)
+}
+
+func (c *Client) post(path string, body []byte) error {
+	resp, err := c.http.Post("http://dock"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return responseError(resp)
+}
+
+// returns an *Error for non 2xx responses
+func responseError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	e := &Error{Code: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(e); err != nil || e.Message == "" {
+		e.Message = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+	}
+	return e
+}
+
+// Wait polls the status until the condition is met, or returns an error once the timeout expired.
+// Errors (i.e. dock isn't listening yet) are retried
+func (c *Client) Wait(timeout, interval time.Duration, condition func(s *Status) bool) (*Status, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s, err := c.Status()
+		if err == nil && condition(s) {
+			return s, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			if err != nil {
+				return nil, fmt.Errorf("timed out after %v: %v", timeout, err)
+			}
+			return s, fmt.Errorf("timed out after %v, process is %s", timeout, s.State)
+		}
+		time.Sleep(interval)
+	}
+}
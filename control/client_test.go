@@ -0,0 +1,72 @@
+package control
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robinmonjo/dock/notifier"
+)
+
+func TestClient(t *testing.T) {
+	s := &fakeSupervisor{}
+	server, _, closeServer := listen(t, s)
+	defer closeServer()
+
+	client := NewClient(server.Path)
+	status, err := client.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != notifier.StatusReady || status.Pid != 7 {
+		t.Fatalf("unexpected status %#v", status)
+	}
+
+	for _, action := range []func() error{client.Restart, client.Rotate, client.Stop} {
+		if err := action(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Signal("HUP"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.actions) != 4 || s.actions[3] != "signal HUP" {
+		t.Fatalf("unexpected actions %v", s.actions)
+	}
+
+	err = client.Signal("FOO")
+	if e, ok := err.(*Error); !ok || e.Code != http.StatusBadRequest || e.Message != `unknown signal "FOO"` {
+		t.Fatalf("expected a bad request error, got %#v", err)
+	}
+}
+
+func TestClientWait(t *testing.T) {
+	s := &fakeSupervisor{}
+	server, _, closeServer := listen(t, s)
+	defer closeServer()
+
+	client := NewClient(server.Path)
+	calls := 0
+	status, err := client.Wait(time.Second, time.Millisecond, func(s *Status) bool {
+		calls++
+		return calls == 3
+	})
+	if err != nil || status == nil || calls != 3 {
+		t.Fatalf("expected the condition to be polled until met, got %d calls (%v)", calls, err)
+	}
+
+	start := time.Now()
+	if _, err := client.Wait(50*time.Millisecond, 10*time.Millisecond, func(*Status) bool { return false }); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the wait to time out after 50ms, took %v", elapsed)
+	}
+
+	// no dock listening
+	missing := NewClient(filepath.Join(filepath.Dir(server.Path), "missing.sock"))
+	if _, err := missing.Wait(50*time.Millisecond, 10*time.Millisecond, func(*Status) bool { return true }); err == nil {
+		t.Fatal("expected an error without control socket")
+	}
+}
@@ -0,0 +1,66 @@
+// Package control serves a small HTTP/JSON API on a unix socket, to query and act on the process
+// supervised by a running dock
+package control
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/robinmonjo/dock/notifier"
+)
+
+// DefaultSocket is the control socket path used by clients when none is given
+const DefaultSocket = "/run/dock.sock"
+
+// Supervisor is implemented by dock to expose its process through the API
+type Supervisor interface {
+	Status() (*Status, error)
+	Signal(name string) error //name as accepted by dock (TERM, SIGTERM, 15)
+	Restart() error
+	Rotate() error
+	Stop() error //graceful, the process isn't restarted
+}
+
+// Status describes the supervised process
+type Status struct {
+	State     notifier.PsStatus `json:"state"`
+	Pid       int               `json:"pid,omitempty"` //0 while the process isn't running
+	Argv      []string          `json:"argv"`
+	StartedAt *time.Time        `json:"started_at,omitempty"`
+	Uptime    float64           `json:"uptime"` //seconds since the process started
+	Restarts  int               `json:"restarts"`
+	DockPid   int               `json:"dock_pid"`
+	Tree      []*Process        `json:"tree"` //dock's children and their descendants
+}
+
+// Process is a node of the process tree
+type Process struct {
+	Pid      int        `json:"pid"`
+	Name     string     `json:"name"`
+	Argv     []string   `json:"argv,omitempty"`
+	State    string     `json:"state"`
+	Threads  int        `json:"threads"`
+	RSS      uint64     `json:"rss"` //bytes
+	Children []*Process `json:"children,omitempty"`
+}
+
+// Error is an API error, reported with its HTTP status code
+type Error struct {
+	Code    int    `json:"-"`
+	Message string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// BadRequest returns an error reported with a 400 status code, i.e. an unknown signal
+func BadRequest(format string, a ...interface{}) error {
+	return &Error{Code: http.StatusBadRequest, Message: fmt.Sprintf(format, a...)}
+}
+
+// Conflict returns an error reported with a 409 status code, i.e. no running process
+func Conflict(format string, a ...interface{}) error {
+	return &Error{Code: http.StatusConflict, Message: fmt.Sprintf(format, a...)}
+}
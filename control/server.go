@@ -0,0 +1,127 @@
+package control
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Server serves the control API:
+//
+//	GET  /status   status of the process (see Status)
+//	POST /signal   send a signal to the process, body: {"signal": "HUP"}
+//	POST /restart  restart the process
+//	POST /rotate   rotate the process logs
+//	POST /stop     stop the process gracefully, dock exits once it's stopped
+//
+// Actions respond with 204 No Content, errors with {"error": "message"}
+type Server struct {
+	Path string
+
+	supervisor Supervisor
+	listener   net.Listener
+}
+
+// Listen creates the unix socket (replacing a stale one) and serves the API in background
+func Listen(path string, s Supervisor) (*Server, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	server := &Server{Path: path, supervisor: s, listener: l}
+	go func() {
+		// returns once the listener is closed
+		http.Serve(l, server.handler())
+	}()
+	return server, nil
+}
+
+// Close stops serving and removes the socket
+func (s *Server) Close() error {
+	return s.listener.Close() //closing a unix listener removes its socket
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if !allow(w, r, "GET") {
+			return
+		}
+		status, err := s.supervisor.Status()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("/signal", func(w http.ResponseWriter, r *http.Request) {
+		if !allow(w, r, "POST") {
+			return
+		}
+		var body struct {
+			Signal string `json:"signal"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, BadRequest("invalid body, expected {\"signal\": \"<name>\"}: %v", err))
+			return
+		}
+		act(w, func() error { return s.supervisor.Signal(body.Signal) })
+	})
+	for path, action := range map[string]func() error{
+		"/restart": s.supervisor.Restart,
+		"/rotate":  s.supervisor.Rotate,
+		"/stop":    s.supervisor.Stop,
+	} {
+		action := action
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if allow(w, r, "POST") {
+				act(w, action)
+			}
+		})
+	}
+	return mux
+}
+
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, &Error{Code: http.StatusMethodNotAllowed, Message: r.Method + " not allowed, expected " + method})
+		return false
+	}
+	return true
+}
+
+func act(w http.ResponseWriter, action func() error) {
+	if err := action(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Code: http.StatusInternalServerError, Message: err.Error()}
+	}
+	writeJSON(w, e.Code, e)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithField("component", "control").Debug(err)
+	}
+}
@@ -0,0 +1,164 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/robinmonjo/dock/notifier"
+)
+
+// fakeSupervisor records the actions it receives
+type fakeSupervisor struct {
+	actions []string
+	err     error
+}
+
+func (s *fakeSupervisor) Status() (*Status, error) {
+	return &Status{State: notifier.StatusReady, Pid: 7, Restarts: 2}, s.err
+}
+
+func (s *fakeSupervisor) Signal(name string) error {
+	if name == "FOO" {
+		return BadRequest("unknown signal %q", name)
+	}
+	s.actions = append(s.actions, "signal "+name)
+	return s.err
+}
+
+func (s *fakeSupervisor) Restart() error { s.actions = append(s.actions, "restart"); return s.err }
+func (s *fakeSupervisor) Rotate() error  { s.actions = append(s.actions, "rotate"); return s.err }
+func (s *fakeSupervisor) Stop() error    { s.actions = append(s.actions, "stop"); return s.err }
+
+func listen(t *testing.T, s Supervisor) (*Server, *http.Client, func()) {
+	dir, err := ioutil.TempDir("", "dock-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := Listen(filepath.Join(dir, "dock.sock"), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", server.Path)
+		},
+	}}
+	return server, client, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := &fakeSupervisor{}
+	_, client, closeServer := listen(t, s)
+	defer closeServer()
+
+	resp, err := client.Get("http://dock/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || status.State != notifier.StatusReady || status.Pid != 7 || status.Restarts != 2 {
+		t.Fatalf("unexpected status %d %#v", resp.StatusCode, status)
+	}
+
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"POST", "/signal", `{"signal": "HUP"}`, http.StatusNoContent},
+		{"POST", "/signal", `{"signal": "FOO"}`, http.StatusBadRequest},
+		{"POST", "/signal", `HUP`, http.StatusBadRequest},
+		{"POST", "/restart", "", http.StatusNoContent},
+		{"GET", "/restart", "", http.StatusMethodNotAllowed},
+		{"POST", "/rotate", "", http.StatusNoContent},
+		{"POST", "/stop", "", http.StatusNoContent},
+		{"POST", "/status", "", http.StatusMethodNotAllowed},
+		{"GET", "/unknown", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, "http://dock"+test.path, bytes.NewBufferString(test.body))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Fatalf("%s %s %s: expected %d, got %d", test.method, test.path, test.body, test.code, resp.StatusCode)
+		}
+	}
+
+	expected := []string{"signal HUP", "restart", "rotate", "stop"}
+	if len(s.actions) != len(expected) {
+		t.Fatalf("expected actions %v, got %v", expected, s.actions)
+	}
+	for i, a := range expected {
+		if s.actions[i] != a {
+			t.Fatalf("expected actions %v, got %v", expected, s.actions)
+		}
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s := &fakeSupervisor{err: Conflict("process is not running")}
+	_, client, closeServer := listen(t, s)
+	defer closeServer()
+
+	resp, err := client.Post("http://dock/restart", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e Error
+	json.NewDecoder(resp.Body).Decode(&e)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict || e.Message != "process is not running" {
+		t.Fatalf("expected a conflict, got %d %q", resp.StatusCode, e.Message)
+	}
+
+	s.err = errors.New("boom")
+	resp, err = client.Get("http://dock/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected an internal error, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	server, _, closeServer := listen(t, &fakeSupervisor{})
+	defer closeServer()
+
+	// a dock that didn't exit cleanly leaves its socket behind
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrUnix{Name: server.Path + ".stale"}); err != nil {
+		t.Fatal(err)
+	}
+	syscall.Close(fd)
+
+	other, err := Listen(server.Path+".stale", &fakeSupervisor{})
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced: %v", err)
+	}
+	other.Close()
+	if _, err := os.Stat(other.Path); !os.IsNotExist(err) {
+		t.Fatalf("expected the socket to be removed on close, got %v", err)
+	}
+}
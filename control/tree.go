@@ -0,0 +1,55 @@
+package control
+
+import (
+	"os"
+
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// Tree returns the descendants of the given process, as a tree whose roots are its children
+func Tree(root *procfs.Proc) ([]*Process, error) {
+	descendants, err := root.Descendants()
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	nodes := map[int]*Process{}
+	parents := map[int]int{}
+	order := []int{} //descendants are listed parents first
+	for _, d := range descendants {
+		status, err := d.Status()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue //exited meanwhile
+			}
+			return nil, err
+		}
+		node := &Process{
+			Pid:     d.Pid,
+			Name:    status.Name,
+			State:   status.State,
+			Threads: status.Threads,
+		}
+		if stat, err := d.Stat(); err == nil {
+			node.RSS = uint64(stat.RSS) * pageSize
+		}
+		if argv, err := d.CmdLine(); err == nil && len(argv) > 0 {
+			node.Argv = argv
+		}
+		nodes[d.Pid] = node
+		parents[d.Pid] = status.PPid
+		order = append(order, d.Pid)
+	}
+
+	tree := []*Process{}
+	for _, pid := range order {
+		node := nodes[pid]
+		if parent, ok := nodes[parents[pid]]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			tree = append(tree, node)
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,43 @@
+package control
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/robinmonjo/dock/procfs"
+)
+
+func TestTree(t *testing.T) {
+	// sh -> sleep
+	cmd := exec.Command("sh", "-c", "sleep 10 & wait")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	var tree []*Process
+	for i := 0; i < 100; i++ {
+		var err error
+		if tree, err = Tree(procfs.Self()); err != nil {
+			t.Fatal(err)
+		}
+		if len(tree) == 1 && len(tree[0].Children) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond) //sh didn't fork yet
+	}
+	if len(tree) != 1 || tree[0].Pid != cmd.Process.Pid || tree[0].Name != "sh" {
+		t.Fatalf("expected sh as only root, got %#v", tree)
+	}
+	if len(tree[0].Children) != 1 {
+		t.Fatalf("expected sleep as child of sh, got %#v", tree[0].Children)
+	}
+	sleep := tree[0].Children[0]
+	if sleep.Name != "sleep" || len(sleep.Argv) != 2 || sleep.RSS == 0 || sleep.Threads != 1 {
+		t.Fatalf("unexpected sleep process %#v", sleep)
+	}
+}
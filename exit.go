@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/robinmonjo/dock/notifier"
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// maximum number of reaped orphans kept for the exit report
+const maxReapedExits = 100
+
+// exit models a process exit: how it ended and the resources it used
+type exit struct {
+	pid        int
+	argv       []string
+	status     int //exit status as a shell would report it (128 + n when killed by signal n)
+	code       int
+	signal     syscall.Signal //0 if the process exited by itself
+	coreDumped bool
+	userTime   time.Duration
+	systemTime time.Duration
+	maxRSS     int64 //kilobytes
+}
+
+// exitReport is written as JSON in the file given to --exit-report when dock exits
+type exitReport struct {
+	Process *notifier.Exit   `json:"process"`
+	Reaped  []*notifier.Exit `json:"reaped"`
+}
+
+func newExit(pid int, ws syscall.WaitStatus, rus *syscall.Rusage) exit {
+	e := exit{
+		pid:        pid,
+		status:     exitStatus(ws),
+		userTime:   time.Duration(rus.Utime.Nano()),
+		systemTime: time.Duration(rus.Stime.Nano()),
+		maxRSS:     rus.Maxrss,
+	}
+	if ws.Signaled() {
+		e.signal = ws.Signal()
+		e.coreDumped = ws.CoreDump()
+	} else {
+		e.code = ws.ExitStatus()
+	}
+	return e
+}
+
+func (e exit) String() string {
+	how := e.how()
+	process := fmt.Sprintf("process %d", e.pid)
+	if len(e.argv) > 0 {
+		process += fmt.Sprintf(" (%s)", strings.Join(e.argv, " "))
+	}
+	return fmt.Sprintf("%s %s, user time: %v, system time: %v, max rss: %d kB", process, how, e.userTime, e.systemTime, e.maxRSS)
+}
+
+// i.e. "exited with code 3" or "killed by SIGSEGV (core dumped)"
+func (e exit) how() string {
+	if e.signal == 0 {
+		return fmt.Sprintf("exited with code %d", e.code)
+	}
+	how := fmt.Sprintf("killed by %s", signalName(e.signal))
+	if e.coreDumped {
+		how += " (core dumped)"
+	}
+	return how
+}
+
+// state returns the process state after the exit
+func (e exit) state(oom *oomWatcher) *notifier.Ps {
+	ps := &notifier.Ps{Status: notifier.StatusExited, Message: e.how(), Exit: e.info()}
+	if e.signal != 0 {
+		ps.Status = notifier.StatusKilled
+	}
+	if oom.oomKilled(e.status) {
+		supervisorLog.WithFields(log.Fields{"pid": e.pid, "state": notifier.StatusOOMKilled}).Error("process killed by the OOM killer")
+		ps.Status = notifier.StatusOOMKilled
+		ps.Message = "killed by the OOM killer"
+	}
+	return ps
+}
+
+// info returns the exit as reported to web hooks and exit report
+func (e exit) info() *notifier.Exit {
+	i := &notifier.Exit{
+		Pid:        e.pid,
+		Argv:       e.argv,
+		Status:     e.status,
+		Code:       e.code,
+		CoreDumped: e.coreDumped,
+		UserTime:   e.userTime.Seconds(),
+		SystemTime: e.systemTime.Seconds(),
+		MaxRSS:     e.maxRSS,
+	}
+	if e.signal != 0 {
+		i.Signal = signalName(e.signal)
+	}
+	return i
+}
+
+// cmdlines caches the command lines of dock's descendants. A dead process (zombie) has an empty
+// /proc/$PID/cmdline, so command lines have to be captured while processes are still alive
+type cmdlines map[int][]string
+
+// snapshot the command lines of the current descendants, forgetting the processes gone (i.e.
+// reaped by their own parent). A zombie keeps the command line captured while it was alive
+func (c cmdlines) snapshot() {
+	descendants, err := procfs.Self().Descendants()
+	if err != nil {
+		signalsLog.Debugf("failed to snapshot command lines: %v", err)
+		return
+	}
+	current := map[int][]string{}
+	for _, d := range descendants {
+		if argv, err := d.CmdLine(); err == nil && len(argv) > 0 {
+			current[d.Pid] = argv //read again, the pid may have been reused
+		} else if argv, ok := c[d.Pid]; ok {
+			current[d.Pid] = argv
+		}
+	}
+	for pid := range c {
+		delete(c, pid)
+	}
+	for pid, argv := range current {
+		c[pid] = argv
+	}
+}
+
+// returns and forget the command line of the given pid
+func (c cmdlines) pop(pid int) []string {
+	argv := c[pid]
+	delete(c, pid)
+	return argv
+}
+
+func writeExitReport(path string, process *exit, reaped []exit) error {
+	report := &exitReport{
+		Reaped: []*notifier.Exit{},
+	}
+	if process != nil {
+		report.Process = process.info()
+	}
+	for _, e := range reaped {
+		report.Reaped = append(report.Reaped, e.info())
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b, 0600)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/robinmonjo/dock/cgroup"
+	"github.com/robinmonjo/dock/notifier"
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// wait statuses as returned by wait4 on linux
+func exitedStatus(code int) syscall.WaitStatus {
+	return syscall.WaitStatus(code << 8)
+}
+
+func signaledStatus(sig syscall.Signal, coreDumped bool) syscall.WaitStatus {
+	ws := syscall.WaitStatus(sig)
+	if coreDumped {
+		ws |= 0x80
+	}
+	return ws
+}
+
+// an oom watcher on the cgroup v2 fixture, that reports one OOM kill
+func testOOMWatcher(t *testing.T) *oomWatcher {
+	root := "./cgroup/assets/v2"
+	cg, err := cgroup.Open(procfs.NewFS(root+"/proc").Proc(1), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &oomWatcher{cgroup: cg}
+}
+
+func TestExitState(t *testing.T) {
+	rus := &syscall.Rusage{
+		Utime:  syscall.Timeval{Sec: 1, Usec: 500000},
+		Stime:  syscall.Timeval{Usec: 250000},
+		Maxrss: 2048,
+	}
+
+	tests := []struct {
+		ws      syscall.WaitStatus
+		oom     bool //oom watcher reporting a kill
+		status  notifier.PsStatus
+		message string
+		exit    notifier.Exit
+	}{
+		{exitedStatus(0), false, notifier.StatusExited, "exited with code 0", notifier.Exit{Status: 0}},
+		{exitedStatus(3), false, notifier.StatusExited, "exited with code 3", notifier.Exit{Status: 3, Code: 3}},
+		{exitedStatus(1), true, notifier.StatusExited, "exited with code 1", notifier.Exit{Status: 1, Code: 1}},
+		// i.e. a shell whose child was OOM killed
+		{exitedStatus(137), true, notifier.StatusOOMKilled, "killed by the OOM killer", notifier.Exit{Status: 137, Code: 137}},
+		{signaledStatus(syscall.SIGTERM, false), false, notifier.StatusKilled, "killed by SIGTERM", notifier.Exit{Status: 143, Signal: "SIGTERM"}},
+		{signaledStatus(syscall.SIGSEGV, true), false, notifier.StatusKilled, "killed by SIGSEGV (core dumped)", notifier.Exit{Status: 139, Signal: "SIGSEGV", CoreDumped: true}},
+		{signaledStatus(syscall.SIGKILL, false), false, notifier.StatusKilled, "killed by SIGKILL", notifier.Exit{Status: 137, Signal: "SIGKILL"}},
+		{signaledStatus(syscall.SIGKILL, false), true, notifier.StatusOOMKilled, "killed by the OOM killer", notifier.Exit{Status: 137, Signal: "SIGKILL"}},
+	}
+
+	for _, test := range tests {
+		var oom *oomWatcher
+		if test.oom {
+			oom = testOOMWatcher(t)
+		}
+
+		e := newExit(42, test.ws, rus)
+		e.argv = []string{"sleep", "1"}
+		ps := e.state(oom)
+		if ps.Status != test.status || ps.Message != test.message {
+			t.Errorf("%#x: expected %s (%s), got %s (%s)", int(test.ws), test.status, test.message, ps.Status, ps.Message)
+		}
+
+		expected := test.exit
+		expected.Pid = 42
+		expected.Argv = []string{"sleep", "1"}
+		expected.UserTime = 1.5
+		expected.SystemTime = 0.25
+		expected.MaxRSS = 2048
+		if !reflect.DeepEqual(*ps.Exit, expected) {
+			t.Errorf("%#x: expected exit %+v, got %+v", int(test.ws), expected, *ps.Exit)
+		}
+	}
+}
+
+func TestOOMKillReportedOnce(t *testing.T) {
+	oom := testOOMWatcher(t)
+	killed := newExit(42, signaledStatus(syscall.SIGKILL, false), &syscall.Rusage{})
+	if s := killed.state(oom).Status; s != notifier.StatusOOMKilled {
+		t.Fatalf("expected %s, got %s", notifier.StatusOOMKilled, s)
+	}
+	// a restarted process isn't reported OOM killed because of the previous run
+	if s := killed.state(oom).Status; s != notifier.StatusKilled {
+		t.Fatalf("expected %s, got %s", notifier.StatusKilled, s)
+	}
+}
+
+func TestCmdlines(t *testing.T) {
+	cmd := exec.Command("sleep", "5")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	c := cmdlines{1: []string{"gone"}}
+	c.snapshot()
+	if argv := c[pid]; !reflect.DeepEqual(argv, []string{"sleep", "5"}) {
+		t.Fatalf("expected the command line of %d, got %v", pid, argv)
+	}
+	if _, ok := c[1]; ok {
+		t.Fatal("expected processes that aren't descendants to be forgotten")
+	}
+
+	// a zombie keeps the command line captured while it was alive
+	cmd.Process.Kill()
+	for i := 0; ; i++ {
+		stat, err := procfs.Self().FS().Proc(pid).Stat()
+		if err == nil && stat.State == "Z" {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("%d didn't become a zombie", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.snapshot()
+	if argv := c[pid]; !reflect.DeepEqual(argv, []string{"sleep", "5"}) {
+		t.Fatalf("expected the command line of zombie %d to be kept, got %v", pid, argv)
+	}
+
+	if argv := c.pop(pid); !reflect.DeepEqual(argv, []string{"sleep", "5"}) {
+		t.Fatalf("expected the command line of %d, got %v", pid, argv)
+	}
+	if argv := c.pop(pid); argv != nil {
+		t.Fatalf("expected the command line of %d to be forgotten, got %v", pid, argv)
+	}
+
+	// reaped processes are forgotten
+	cmd.Wait()
+	c[pid] = []string{"sleep", "5"}
+	c.snapshot()
+	if _, ok := c[pid]; ok {
+		t.Fatalf("expected reaped %d to be forgotten", pid)
+	}
+}
+
+func TestWriteExitReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-exit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "exit.json")
+
+	process := newExit(10, exitedStatus(1), &syscall.Rusage{Maxrss: 1024})
+	process.argv = []string{"app", "--serve"}
+	orphan := newExit(11, signaledStatus(syscall.SIGTERM, false), &syscall.Rusage{})
+
+	tests := []struct {
+		process  *exit
+		reaped   []exit
+		expected exitReport
+	}{
+		{nil, nil, exitReport{Reaped: []*notifier.Exit{}}},
+		{&process, nil, exitReport{
+			Process: &notifier.Exit{Pid: 10, Argv: []string{"app", "--serve"}, Status: 1, Code: 1, MaxRSS: 1024},
+			Reaped:  []*notifier.Exit{},
+		}},
+		{&process, []exit{orphan}, exitReport{
+			Process: &notifier.Exit{Pid: 10, Argv: []string{"app", "--serve"}, Status: 1, Code: 1, MaxRSS: 1024},
+			Reaped:  []*notifier.Exit{{Pid: 11, Status: 143, Signal: "SIGTERM"}},
+		}},
+	}
+
+	for i, test := range tests {
+		if err := writeExitReport(path, test.process, test.reaped); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("#%d: expected mode 0600, got %v", i, perm)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report exitReport
+		err = json.NewDecoder(f).Decode(&report)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(report, test.expected) {
+			t.Errorf("#%d: expected %+v, got %+v", i, test.expected, report)
+		}
+	}
+}
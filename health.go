@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/robinmonjo/dock/health"
+	"github.com/robinmonjo/dock/notifier"
+)
+
+// health of the process, as reported by the --health-addr endpoints
+func healthCheck(p *process) *health.Check {
+	ps := &notifier.Ps{}
+	p.describe(ps)
+	last := lifecycle.Last()
+
+	return &health.Check{
+		State:           last.Status,
+		Message:         last.Message,
+		Pid:             p.runningPid(),
+		Uptime:          p.uptime().Seconds(),
+		Restarts:        ps.Restarts,
+		ReadinessSource: readinessSource(p),
+		Port:            ps.Port,
+	}
+}
+
+// what makes the process ready
+func readinessSource(p *process) string {
+	if p.bindPort != "" {
+		return health.ReadyOnBindPort
+	}
+	return health.ReadyOnStart
+}
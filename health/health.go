@@ -0,0 +1,112 @@
+// Package health serves liveness and readiness endpoints for orchestrators (i.e. Kubernetes
+// probes), answered from the state of the process supervised by dock
+package health
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/robinmonjo/dock/notifier"
+)
+
+// readiness sources
+const (
+	ReadyOnStart    = "start"     //the process is ready once started
+	ReadyOnBindPort = "bind-port" //the process is ready once it bound the expected port
+)
+
+// Check describes the process, it's the body of both endpoints
+type Check struct {
+	Live            bool              `json:"live"`
+	Ready           bool              `json:"ready"`
+	State           notifier.PsStatus `json:"state"`
+	Message         string            `json:"message,omitempty"` //why the process entered its state
+	Pid             int               `json:"pid,omitempty"`     //0 while the process isn't running
+	Uptime          float64           `json:"uptime"`            //seconds since the process started
+	Restarts        int               `json:"restarts"`
+	ReadinessSource string            `json:"readiness_source"`
+	Port            *notifier.Port    `json:"port,omitempty"`
+}
+
+// live while dock supervises the process: from the init steps to its exit, restarts included.
+// An unhealthy process is live but not ready
+func (c *Check) live() bool {
+	switch c.State {
+	case notifier.StatusInitializing, notifier.StatusStarting, notifier.StatusReady,
+		notifier.StatusUnhealthy, notifier.StatusStopping, notifier.StatusRestarting:
+		return true
+	}
+	return false
+}
+
+func (c *Check) ready() bool {
+	return c.Pid != 0 && c.State == notifier.StatusReady
+}
+
+// Server serves the endpoints:
+//
+//	GET /healthz  200 if the process is live, 503 otherwise
+//	GET /readyz   200 if the process is ready, 503 otherwise
+//
+// Both respond with the check as JSON
+type Server struct {
+	Addr string
+
+	listener net.Listener
+}
+
+// Listen serves the endpoints in background, check is called on each request
+func Listen(addr string, check func() *Check) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		// returns once the listener is closed
+		http.Serve(l, Handler(check))
+	}()
+	return &Server{Addr: l.Addr().String(), listener: l}, nil
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Handler answers /healthz and /readyz
+func Handler(check func() *Check) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, r, check, func(c *Check) bool { return c.Live })
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, r, check, func(c *Check) bool { return c.Ready })
+	})
+	return mux
+}
+
+func respond(w http.ResponseWriter, r *http.Request, check func() *Check, ok func(c *Check) bool) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, r.Method+" not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c := check()
+	c.Live = c.live()
+	c.Ready = c.ready()
+
+	code := http.StatusOK
+	if !ok(c) {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == "HEAD" {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		log.WithField("component", "health").Debug(err)
+	}
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/robinmonjo/dock/notifier"
+)
+
+func TestEndpoints(t *testing.T) {
+	check := &Check{}
+	server := httptest.NewServer(Handler(func() *Check {
+		c := *check
+		return &c
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		state   notifier.PsStatus
+		pid     int
+		healthz int
+		readyz  int
+	}{
+		{notifier.StatusInitializing, 0, http.StatusOK, http.StatusServiceUnavailable},
+		{notifier.StatusStarting, 7, http.StatusOK, http.StatusServiceUnavailable},
+		{notifier.StatusReady, 7, http.StatusOK, http.StatusOK},
+		{notifier.StatusUnhealthy, 7, http.StatusOK, http.StatusServiceUnavailable},
+		{notifier.StatusStopping, 7, http.StatusOK, http.StatusServiceUnavailable},
+		{notifier.StatusRestarting, 0, http.StatusOK, http.StatusServiceUnavailable},
+		{notifier.StatusExited, 0, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{notifier.StatusFailedToStart, 0, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		check.State = c.state
+		check.Pid = c.pid
+		for path, expected := range map[string]int{"/healthz": c.healthz, "/readyz": c.readyz} {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body Check
+			err = json.NewDecoder(resp.Body).Decode(&body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != expected {
+				t.Fatalf("%s: expected %d when %s, got %d", path, expected, c.state, resp.StatusCode)
+			}
+			if body.State != c.state || body.Live != (c.healthz == http.StatusOK) || body.Ready != (c.readyz == http.StatusOK) {
+				t.Fatalf("%s: unexpected body %#v", path, body)
+			}
+		}
+	}
+
+	resp, err := http.Post(server.URL+"/readyz", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.StatusCode)
+	}
+}
+
+func TestListen(t *testing.T) {
+	server, err := Listen("127.0.0.1:0", func() *Check {
+		return &Check{State: notifier.StatusReady, Pid: 7}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	resp, err := http.Head("http://" + server.Addr + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
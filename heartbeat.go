@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robinmonjo/dock/notifier"
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// heartbeat periodically notifies that the process is alive, with the resources used by its tree,
+// so that receivers can spot dead containers by missing heartbeats
+type heartbeat struct {
+	interval time.Duration
+}
+
+func newHeartbeat(interval string) (*heartbeat, error) {
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("heartbeat interval must be positive, got %s", interval)
+	}
+	return &heartbeat{interval: d}, nil
+}
+
+// notify alive statuses until the stop channel is closed
+func (h *heartbeat) beat(p *process, stop <-chan bool) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ps := &notifier.Ps{
+			Status: notifier.StatusAlive,
+			Uptime: p.uptime().Seconds(),
+		}
+		// an alive status without resources is still worth sending
+		u, err := treeUsage(procfs.Self())
+		if err != nil {
+			heartbeatLog.Error(err)
+		} else {
+			ps.Resources = u.resources()
+		}
+		notify(p, ps)
+	}
+}
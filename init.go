@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/robinmonjo/dock/iowire"
+	"github.com/robinmonjo/dock/notifier"
+)
+
+const (
+	defaultInitDir     = "/etc/dock/init.d"
+	defaultInitTimeout = 5 * time.Minute
+)
+
+// initStep is run before the process is started, like phusion's my_init scripts
+type initStep struct {
+	name string //script file name or command line, reported in notifications
+	argv []string
+}
+
+// init steps: the executables of dir in name order, then the commands (split on spaces). A
+// missing dir has no executables
+func initSteps(dir string, commands []string) ([]*initStep, error) {
+	steps := []*initStep{}
+	if dir != "" {
+		files, err := ioutil.ReadDir(dir) //sorted by name
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, f := range files {
+			path := filepath.Join(dir, f.Name())
+			fi, err := os.Stat(path) //follow symlinks
+			if err != nil {
+				return nil, err
+			}
+			if !fi.Mode().IsRegular() || fi.Mode()&0111 == 0 {
+				supervisorLog.Debugf("init: skipping %s, not an executable", path)
+				continue
+			}
+			steps = append(steps, &initStep{name: f.Name(), argv: []string{path}})
+		}
+	}
+
+	for _, command := range commands {
+		argv := strings.Fields(command)
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("empty init command")
+		}
+		path, err := exec.LookPath(argv[0])
+		if err != nil {
+			return nil, fmt.Errorf("init %s: %v", command, err)
+		}
+		argv[0] = path
+		steps = append(steps, &initStep{name: strings.TrimSpace(command), argv: argv})
+	}
+	return steps, nil
+}
+
+// run the init steps in order, their output goes through the wire. Each step is notified as
+// initializing, the first one failing (non zero exit or timeout) aborts the startup, and so does
+// a stopping signal (passed on to the running step)
+func runInit(steps []*initStep, wire *iowire.Wire, timeout time.Duration, sh *signalsHandler) error {
+	for _, step := range steps {
+		processStateChanged(&notifier.Ps{Status: notifier.StatusInitializing, Message: step.name})
+		if err := step.run(wire, timeout, sh); err != nil {
+			return fmt.Errorf("init %s: %v", step.name, err)
+		}
+	}
+	return nil
+}
+
+// run the step in its own process group, killed as a whole on timeout. The output goes through
+// a pipe of ours: with one of exec, waiting for the step would also wait for the processes it
+// started in background
+func (s *initStep) run(wire *iowire.Wire, timeout time.Duration, sh *signalsHandler) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(s.argv[0], s.argv[1:]...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGTERM,
+	}
+
+	supervisorLog.Debugf("init: running %s", s.name)
+	wait, err := sh.start(cmd)
+	w.Close() //the step and its children hold their own copy
+	if err != nil {
+		r.Close()
+		return err
+	}
+	go func() {
+		io.Copy(wire, r) //until every process of the step exited (or closed its output)
+		r.Close()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- wait()
+	}()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	pgid := cmd.Process.Pid
+	var abort error //why the step was interrupted
+	for {
+		select {
+		case err := <-done:
+			if abort != nil {
+				return abort
+			}
+			return err
+
+		case <-deadline:
+			supervisorLog.Debugf("init: %s timed out, killing its process group", s.name)
+			syscall.Kill(-pgid, syscall.SIGKILL)
+			abort = fmt.Errorf("timed out after %s", timeout)
+
+		case sig := <-sh.signals:
+			if !sh.forwardInit(sig, pgid) || abort != nil {
+				continue
+			}
+			abort = fmt.Errorf("%s received", osSignalName(sig))
+			killTimer := time.AfterFunc(killTimeout*time.Second, func() {
+				supervisorLog.Debugf("init: %s still running after %ds, killing its process group", s.name, killTimeout)
+				syscall.Kill(-pgid, syscall.SIGKILL)
+			})
+			defer killTimer.Stop()
+		}
+	}
+}
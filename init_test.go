@@ -0,0 +1,256 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/robinmonjo/dock/iowire"
+	"github.com/robinmonjo/dock/notifier"
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// a signals handler that doesn't catch signals, its channel is fed by tests
+func testSignalsHandler() *signalsHandler {
+	return &signalsHandler{
+		signals:  make(chan os.Signal, signalBufferSize),
+		cmdlines: cmdlines{},
+		commands: map[int]chan exit{},
+	}
+}
+
+func writeScript(t *testing.T, dir, name, script string, mode os.FileMode) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runs the steps with a file wire, returns the path of the wire and the init steps notified
+func testRunInit(t *testing.T, dir string, steps []*initStep, timeout time.Duration, sh *signalsHandler) (string, []string, error) {
+	output := filepath.Join(dir, "output.log")
+	os.Remove(output)
+	// not closed: the output of steps is copied in background, and its input is stdin
+	wire, err := iowire.NewWire("file://" + output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(l *notifier.Lifecycle) { lifecycle = l }(lifecycle)
+	notified := []string{}
+	lifecycle = notifier.NewLifecycle(func(ps *notifier.Ps) {
+		notified = append(notified, ps.Message)
+	})
+
+	err = runInit(steps, wire, timeout, sh)
+	return output, notified, err
+}
+
+// the output of steps is copied in background, it may be written after they exited
+func checkOutput(t *testing.T, path, expected string) {
+	var output string
+	for i := 0; i < 100; i++ {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output = string(b); output == expected {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected output %q, got %q", expected, output)
+}
+
+func TestInitSteps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeScript(t, dir, "20-second", "echo second", 0755)
+	writeScript(t, dir, "10-first", "echo first", 0755)
+	writeScript(t, dir, "15-not-executable", "echo skipped", 0644)
+	if err := os.Mkdir(filepath.Join(dir, "17-dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "10-first"), filepath.Join(dir, "30-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir      string
+		commands []string
+		expected []*initStep //nil if invalid
+	}{
+		{"", nil, []*initStep{}},
+		{filepath.Join(dir, "missing"), nil, []*initStep{}},
+		{dir, nil, []*initStep{
+			{name: "10-first", argv: []string{filepath.Join(dir, "10-first")}},
+			{name: "20-second", argv: []string{filepath.Join(dir, "20-second")}},
+			{name: "30-link", argv: []string{filepath.Join(dir, "30-link")}},
+		}},
+		{dir, []string{" /bin/echo  third ", "/bin/echo fourth"}, []*initStep{
+			{name: "10-first", argv: []string{filepath.Join(dir, "10-first")}},
+			{name: "20-second", argv: []string{filepath.Join(dir, "20-second")}},
+			{name: "30-link", argv: []string{filepath.Join(dir, "30-link")}},
+			{name: "/bin/echo  third", argv: []string{"/bin/echo", "third"}},
+			{name: "/bin/echo fourth", argv: []string{"/bin/echo", "fourth"}},
+		}},
+
+		{"", []string{" "}, nil},
+		{"", []string{"dock-init-command-not-found"}, nil},
+		{"", []string{filepath.Join(dir, "15-not-executable")}, nil},
+		{filepath.Join(dir, "10-first"), nil, nil},
+	}
+
+	for _, test := range tests {
+		steps, err := initSteps(test.dir, test.commands)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("%s %q: expected an error", test.dir, test.commands)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: %v", test.dir, test.commands, err)
+			continue
+		}
+		if !reflect.DeepEqual(steps, test.expected) {
+			t.Errorf("%s %q: expected %d steps, got %d", test.dir, test.commands, len(test.expected), len(steps))
+			for _, s := range steps {
+				t.Logf("%s %v", s.name, s.argv)
+			}
+		}
+	}
+}
+
+func TestRunInit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeScript(t, dir, "10-first", "echo first", 0755)
+	writeScript(t, dir, "20-second", "echo second >&2", 0755)
+	steps, err := initSteps(dir, []string{"/bin/echo third"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, notified, err := testRunInit(t, dir, steps, time.Second, testSignalsHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOutput(t, output, "first\nsecond\nthird\n")
+	if expected := []string{"10-first", "20-second", "/bin/echo third"}; !reflect.DeepEqual(notified, expected) {
+		t.Fatalf("expected %v to be notified, got %v", expected, notified)
+	}
+
+	// the first step failing aborts the startup
+	writeScript(t, dir, "15-failing", "exit 3", 0755)
+	steps, err = initSteps(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, notified, err = testRunInit(t, dir, steps, time.Second, testSignalsHandler())
+	if err == nil || !strings.Contains(err.Error(), "15-failing") {
+		t.Fatalf("expected 15-failing to fail, got %v", err)
+	}
+	checkOutput(t, output, "first\n")
+	if expected := []string{"10-first", "15-failing"}; !reflect.DeepEqual(notified, expected) {
+		t.Fatalf("expected %v to be notified, got %v", expected, notified)
+	}
+}
+
+// waits for the process to be gone (or a zombie, until its new parent reaps it)
+func waitGone(t *testing.T, pid int) {
+	for i := 0; i < 200; i++ {
+		stat, err := procfs.Self().FS().Proc(pid).Stat()
+		if err != nil || stat.State == "Z" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+	t.Fatalf("process %d is still running", pid)
+}
+
+// the pid of the background process written by the step
+func backgroundPid(t *testing.T, path string) int {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pid
+}
+
+func TestRunInitTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pidFile := filepath.Join(dir, "background.pid")
+	writeScript(t, dir, "10-hanging", "sleep 30 &\necho $! > "+pidFile+"\nsleep 30", 0755)
+	steps, err := initSteps(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, _, err = testRunInit(t, dir, steps, 200*time.Millisecond, testSignalsHandler())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the step to be killed right away, took %v", elapsed)
+	}
+	// the whole process group is killed, not only the step
+	waitGone(t, backgroundPid(t, pidFile))
+}
+
+func TestRunInitStopped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pidFile := filepath.Join(dir, "background.pid")
+	writeScript(t, dir, "10-hanging", "sleep 30 &\necho $! > "+pidFile+"\nsleep 30", 0755)
+	writeScript(t, dir, "20-never", "echo never", 0755)
+	steps, err := initSteps(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sh := testSignalsHandler()
+	go func() {
+		for i := 0; i < 200; i++ {
+			if _, err := os.Stat(pidFile); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		sh.signals <- syscall.SIGTERM
+	}()
+	output, _, err := testRunInit(t, dir, steps, time.Minute, sh)
+	if err == nil || !strings.Contains(err.Error(), "SIGTERM received") {
+		t.Fatalf("expected the startup to be aborted, got %v", err)
+	}
+	checkOutput(t, output, "")
+	// the signal is passed on to the process group of the step
+	waitGone(t, backgroundPid(t, pidFile))
+}
@@ -0,0 +1,6 @@
+#!/bin/bash
+#bash script used to test init steps starting processes in background
+#the background sleep keeps the step output open, the script then sleeps for the given seconds
+sleep 600 &
+echo "init done"
+sleep ${1:-0}
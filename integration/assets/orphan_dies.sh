@@ -0,0 +1,8 @@
+#!/bin/bash
+
+#bash script used to test that an orphan dying doesn't stop the process
+
+#the subshell exits right away, sleep is adopted by dock and dies before the script exits
+(sleep 0.2 &)
+sleep 1
+echo "still running"
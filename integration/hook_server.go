@@ -9,7 +9,7 @@ import (
 )
 
 type hookServer struct {
-	c chan notifier.PsStatus
+	c chan *notifier.HookPayload
 	t *testing.T
 }
 
@@ -26,7 +26,7 @@ func (s *hookServer) start() (host, port string) {
 			s.t.Fatal(err)
 		}
 
-		s.c <- payload.Ps.Status
+		s.c <- &payload
 	})
 
 	go func() {
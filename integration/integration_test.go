@@ -1,9 +1,13 @@
 package integration
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/robinmonjo/dock/notifier"
 )
@@ -42,9 +46,35 @@ func TestOrphanProcessReaping(t *testing.T) {
 	}
 }
 
+func TestOrphanDeathKeepsProcessRunning(t *testing.T) {
+	fmt.Println("testing an orphan death doesn't stop the process")
+	c := make(chan *notifier.HookPayload, 3)
+
+	server.c = c
+	server.t = t
+
+	d := newDocker()
+
+	if err := d.start(false, "run", testImage, "dock", "--web-hook", serverURL, "bash", "/go/src/github.com/robinmonjo/dock/integration/assets/orphan_dies.sh"); err != nil {
+		fmt.Println(d.debugInfo())
+		t.Fatal(err)
+	}
+
+	for _, status := range []notifier.PsStatus{notifier.StatusStarting, notifier.StatusReady, notifier.StatusExited} {
+		p := <-server.c
+		if p.Ps.Status != status {
+			t.Fatalf("expected status %q, got %q", status, p.Ps.Status)
+		}
+		// the script exits by itself once the orphan died, it isn't terminated by dock
+		if status == notifier.StatusExited && (p.Ps.Exit == nil || p.Ps.Exit.Code != 0 || p.Ps.Exit.Signal != "") {
+			t.Fatalf("expected the script to exit by itself, got %#v", p.Ps.Exit)
+		}
+	}
+}
+
 func TestWebHook(t *testing.T) {
 	fmt.Println("testing web hook call")
-	c := make(chan notifier.PsStatus, 3)
+	c := make(chan *notifier.HookPayload, 3)
 
 	server.c = c
 	server.t = t
@@ -56,17 +86,250 @@ func TestWebHook(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	for _, status := range []notifier.PsStatus{notifier.StatusStarting, notifier.StatusRunning, notifier.StatusCrashed} {
-		s := <-server.c
-		if s != status {
-			t.Fatalf("expected status %q, got %q", notifier.StatusStarting, status)
+	var seq uint64
+	for _, status := range []notifier.PsStatus{notifier.StatusStarting, notifier.StatusReady, notifier.StatusExited} {
+		p := <-server.c
+		if p.Ps.Status != status {
+			t.Fatalf("expected status %q, got %q", status, p.Ps.Status)
 		}
+		checkPayload(t, p, seq)
+		seq = p.Seq
+	}
+}
+
+func TestWebHookPayload(t *testing.T) {
+	fmt.Println("testing web hook payload")
+	c := make(chan *notifier.HookPayload, 3)
+
+	server.c = c
+	server.t = t
+
+	d := newDocker()
+
+	if err := d.start(false, "run", testImage, "dock", "--web-hook", serverURL, "bash", "-c", "exit 3"); err != nil {
+		fmt.Println(d.debugInfo())
+		t.Fatal(err)
+	}
+
+	p := <-server.c
+	if p.Ps.Status != notifier.StatusStarting {
+		t.Fatalf("expected status %q, got %q", notifier.StatusStarting, p.Ps.Status)
+	}
+	checkPayload(t, p, 0)
+	if p.Ps.Pid != 0 || p.Ps.StartedAt != nil {
+		t.Fatalf("expected no pid nor start time before the process starts, got %d, %v", p.Ps.Pid, p.Ps.StartedAt)
+	}
+	if len(p.Ps.Argv) != 3 || p.Ps.Argv[0] != "bash" {
+		t.Fatalf("unexpected argv %v", p.Ps.Argv)
+	}
+
+	p = <-server.c
+	if p.Ps.Status != notifier.StatusReady {
+		t.Fatalf("expected status %q, got %q", notifier.StatusReady, p.Ps.Status)
+	}
+	checkPayload(t, p, 1)
+	if p.Ps.Pid == 0 || p.Ps.StartedAt == nil {
+		t.Fatalf("expected pid and start time once the process started, got %d, %v", p.Ps.Pid, p.Ps.StartedAt)
+	}
+	pid := p.Ps.Pid
+
+	p = <-server.c
+	if p.Ps.Status != notifier.StatusExited {
+		t.Fatalf("expected status %q, got %q", notifier.StatusExited, p.Ps.Status)
+	}
+	checkPayload(t, p, 2)
+	if p.Ps.Exit == nil || p.Ps.Exit.Pid != pid || p.Ps.Exit.Code != 3 || p.Ps.Exit.Signal != "" {
+		t.Fatalf("expected process %d to exit with code 3, got %#v", pid, p.Ps.Exit)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	fmt.Println("testing heartbeat")
+	c := make(chan *notifier.HookPayload, 10)
+
+	server.c = c
+	server.t = t
+
+	d := newDocker()
+
+	if err := d.start(false, "run", testImage, "dock", "--web-hook", serverURL+";events=alive,exited", "--heartbeat", "500ms", "bash", "-c", "sleep 2"); err != nil {
+		fmt.Println(d.debugInfo())
+		t.Fatal(err)
+	}
+
+	alive := 0
+	for p := range server.c {
+		if p.Ps.Status == notifier.StatusExited {
+			break
+		}
+		if p.Ps.Status != notifier.StatusAlive {
+			t.Fatalf("expected status %q, got %q", notifier.StatusAlive, p.Ps.Status)
+		}
+		if p.Ps.Uptime <= 0 || p.Ps.Resources == nil || p.Ps.Resources.Descendants == 0 || p.Ps.Resources.RSS == 0 || p.Ps.Resources.OpenFds == 0 {
+			t.Fatalf("expected uptime and resources, got %v, %#v", p.Ps.Uptime, p.Ps.Resources)
+		}
+		alive++
+	}
+	if alive < 2 {
+		t.Fatalf("expected at least 2 heartbeats, got %d", alive)
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	fmt.Println("testing state file")
+	d := newDocker()
+
+	// the process prints its pid and the state file while it runs, the state file is printed
+	// again once dock exited
+	script := "dock --state-file /run/dock/state.json bash -c 'echo $$; sleep 0.5; cat /run/dock/state.json; exit 3'; cat /run/dock/state.json"
+	if err := d.start(false, "run", testImage, "bash", "-c", script); err != nil {
+		fmt.Println(d.debugInfo())
+		t.Fatal(err)
+	}
+
+	type state struct {
+		State    notifier.PsStatus `json:"state"`
+		Pid      int               `json:"pid"`
+		LastExit *notifier.Exit    `json:"last_exit"`
+		DockPid  int               `json:"dock_pid"`
+	}
+	var (
+		pid            int
+		running, final state
+	)
+	dec := json.NewDecoder(bytes.NewReader(d.stdout))
+	for _, v := range []interface{}{&pid, &running, &final} {
+		if err := dec.Decode(v); err != nil {
+			fmt.Println(d.debugInfo())
+			t.Fatal(err)
+		}
+	}
+
+	if running.State != notifier.StatusReady || running.Pid != pid || running.LastExit != nil || running.DockPid == 0 {
+		t.Fatalf("expected the running process state with pid %d, got %#v", pid, running)
+	}
+	if final.State != notifier.StatusExited || final.Pid != 0 || final.DockPid != running.DockPid {
+		t.Fatalf("expected the exited process state, got %#v", final)
+	}
+	if final.LastExit == nil || final.LastExit.Code != 3 || final.LastExit.Signal != "" {
+		t.Fatalf("expected the process to exit with 3, got %#v", final.LastExit)
+	}
+}
+
+func TestInitBackground(t *testing.T) {
+	fmt.Println("testing an init step starting a process in background")
+	c := make(chan *notifier.HookPayload, 5)
+
+	server.c = c
+	server.t = t
+
+	d := newDocker()
+
+	// the step exits right away, the background sleep must not delay the process
+	start := time.Now()
+	if err := d.start(false, "run", testImage, "dock", "--web-hook", serverURL, "--init-command", "bash /go/src/github.com/robinmonjo/dock/integration/assets/init_background.sh", "ls"); err != nil {
+		fmt.Println(d.debugInfo())
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("expected the process not to wait for the background sleep, took %v", elapsed)
+	}
+
+	for _, status := range []notifier.PsStatus{notifier.StatusInitializing, notifier.StatusStarting, notifier.StatusReady, notifier.StatusExited} {
+		p := <-server.c
+		if p.Ps.Status != status {
+			t.Fatalf("expected status %q, got %q", status, p.Ps.Status)
+		}
+	}
+}
+
+func TestInitTimeout(t *testing.T) {
+	fmt.Println("testing init timeout with a process in background")
+	c := make(chan *notifier.HookPayload, 2)
+
+	server.c = c
+	server.t = t
+
+	d := newDocker()
+
+	// the step and its background sleep are killed after the timeout
+	start := time.Now()
+	err := d.start(false, "run", testImage, "dock", "--web-hook", serverURL, "--init-command", "bash /go/src/github.com/robinmonjo/dock/integration/assets/init_background.sh 600", "--init-timeout", "1s", "ls")
+	if err == nil {
+		t.Fatal("expected dock to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("expected the startup to be aborted after the timeout, took %v", elapsed)
+	}
+
+	for _, status := range []notifier.PsStatus{notifier.StatusInitializing, notifier.StatusFailedToStart} {
+		p := <-server.c
+		if p.Ps.Status != status {
+			t.Fatalf("expected status %q, got %q", status, p.Ps.Status)
+		}
+		if status == notifier.StatusFailedToStart && !strings.Contains(p.Ps.Message, "timed out after 1s") {
+			t.Fatalf("expected a timeout, got %q", p.Ps.Message)
+		}
+	}
+}
+
+func TestInitStop(t *testing.T) {
+	fmt.Println("testing docker stop during init")
+	c := make(chan *notifier.HookPayload, 2)
+
+	server.c = c
+	server.t = t
+
+	d := newDocker()
+	name := "dock-test-init-container"
+
+	if err := d.start(false, "run", "-d", "--name", name, testImage, "dock", "--web-hook", serverURL, "--init-command", "sleep 600", "ls"); err != nil {
+		fmt.Println(d.debugInfo())
+		t.Fatal(err)
+	}
+
+	defer d.start(false, "rm", name)
+
+	p := <-server.c
+	if p.Ps.Status != notifier.StatusInitializing {
+		t.Fatalf("expected status %q, got %q", notifier.StatusInitializing, p.Ps.Status)
+	}
+
+	// the SIGTERM is passed on to the init step and the process is never started
+	if err := d.start(false, "stop", name); err != nil {
+		t.Fatal(err)
+	}
+	p = <-server.c
+	if p.Ps.Status != notifier.StatusFailedToStart || !strings.Contains(p.Ps.Message, "SIGTERM received") {
+		t.Fatalf("expected status %q after SIGTERM, got %q (%s)", notifier.StatusFailedToStart, p.Ps.Status, p.Ps.Message)
+	}
+}
+
+// check the context common to all payloads
+func checkPayload(t *testing.T, p *notifier.HookPayload, previousSeq uint64) {
+	if p.Version != notifier.PayloadVersion {
+		t.Fatalf("expected payload version %d, got %d", notifier.PayloadVersion, p.Version)
+	}
+	if p.Seq != previousSeq+1 {
+		t.Fatalf("expected sequence number %d, got %d", previousSeq+1, p.Seq)
+	}
+	if p.Timestamp.IsZero() {
+		t.Fatal("expected event timestamp")
+	}
+	if p.Hostname == "" {
+		t.Fatal("expected hostname")
+	}
+	if len(p.ContainerID) != 64 {
+		t.Fatalf("expected a docker container id, got %q", p.ContainerID)
+	}
+	if p.DockVersion == "" {
+		t.Fatal("expected dock version")
 	}
 }
 
 func TestPortBindingHook(t *testing.T) {
-	fmt.Println("testing process is not considered running if specified port is not bound")
-	c := make(chan notifier.PsStatus, 3)
+	fmt.Println("testing process is not considered ready if specified port is not bound")
+	c := make(chan *notifier.HookPayload, 3)
 
 	server.c = c
 	server.t = t
@@ -78,18 +341,21 @@ func TestPortBindingHook(t *testing.T) {
 		fmt.Println(d.debugInfo())
 		t.Fatal(err)
 	}
-	// ls will never bind the port, should never see the "running status"
-	for _, status := range []notifier.PsStatus{notifier.StatusStarting, notifier.StatusCrashed} {
-		s := <-server.c
-		if s != status {
-			t.Fatalf("expected status %q, got %q", notifier.StatusStarting, status)
+	// ls will never bind the port, should never see the "ready" status
+	for _, status := range []notifier.PsStatus{notifier.StatusStarting, notifier.StatusExited} {
+		p := <-server.c
+		if p.Ps.Status != status {
+			t.Fatalf("expected status %q, got %q", status, p.Ps.Status)
+		}
+		if p.Ps.Port == nil || p.Ps.Port.Port != port || p.Ps.Port.Bound {
+			t.Fatalf("expected port %s not to be bound, got %#v", port, p.Ps.Port)
 		}
 	}
 }
 
 func TestPortBinding(t *testing.T) {
 	fmt.Println("testing web hook with port binding")
-	c := make(chan notifier.PsStatus, 3)
+	c := make(chan *notifier.HookPayload, 4)
 
 	server.c = c
 	server.t = t
@@ -105,21 +371,31 @@ func TestPortBinding(t *testing.T) {
 
 	defer d.start(false, "rm", name)
 
-	s := <-server.c
-	if s != notifier.StatusStarting {
-		t.Fatalf("expected status %q, got %q", notifier.StatusStarting, s)
+	p := <-server.c
+	if p.Ps.Status != notifier.StatusStarting {
+		t.Fatalf("expected status %q, got %q", notifier.StatusStarting, p.Ps.Status)
 	}
 
-	s = <-server.c
-	if s != notifier.StatusRunning {
-		t.Fatalf("expected status %q, got %q", notifier.StatusRunning, s)
+	p = <-server.c
+	if p.Ps.Status != notifier.StatusReady {
+		t.Fatalf("expected status %q, got %q", notifier.StatusReady, p.Ps.Status)
+	}
+	// strict port binding: the binder is python itself, the only child of dock
+	if p.Ps.Port == nil || !p.Ps.Port.Bound || p.Ps.Port.BinderPid != p.Ps.Pid {
+		t.Fatalf("expected port %s to be bound by %d, got %#v", port, p.Ps.Pid, p.Ps.Port)
 	}
 
 	if err := d.start(false, "stop", name); err != nil {
 		t.Fatal(err)
 	}
-	s = <-server.c
-	if s != notifier.StatusCrashed {
-		t.Fatalf("expected status %q, got %q", notifier.StatusCrashed, s)
+	// docker stop sends SIGTERM to dock, forwarded to python
+	for _, status := range []notifier.PsStatus{notifier.StatusStopping, notifier.StatusKilled} {
+		p = <-server.c
+		if p.Ps.Status != status {
+			t.Fatalf("expected status %q, got %q", status, p.Ps.Status)
+		}
+	}
+	if p.Ps.Exit == nil || p.Ps.Exit.Signal != "SIGTERM" {
+		t.Fatalf("expected python to be killed by SIGTERM, got %#v", p.Ps.Exit)
 	}
 }
@@ -1,12 +1,14 @@
 package iowire
 
 import (
+	"bytes"
 	"crypto/tls"
 	"io"
 	"net"
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/pkg/term"
@@ -30,6 +32,9 @@ const (
 )
 
 type Wire struct {
+	written uint64 //bytes written, first for 64 bits alignment of atomic operations
+	lines   uint64 //lines written
+
 	URL     *url.URL
 	prefix  []byte
 	Input   io.Reader
@@ -38,6 +43,13 @@ type Wire struct {
 }
 
 func NewWire(uri string) (*Wire, error) {
+	return NewWireWithTLS(uri, nil)
+}
+
+// NewWireWithTLS creates a wire, tls and ssl schemes use the given configuration. If nil or if the
+// configuration has no ServerName, server certificates are verified against the host dialed.
+// The handshake is done before returning so that certificate errors are reported right away
+func NewWireWithTLS(uri string, config *tls.Config) (*Wire, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
@@ -66,12 +78,11 @@ func NewWire(uri string) (*Wire, error) {
 	case "ssl":
 		fallthrough
 	case "tls":
-		tcpConn, err := net.DialTimeout("tcp", path, DIAL_TIMEOUT)
+		dialer := &net.Dialer{Timeout: DIAL_TIMEOUT}
+		conn, err := tls.DialWithDialer(dialer, "tcp", path, config)
 		if err != nil {
 			return nil, err
 		}
-		config := &tls.Config{InsecureSkipVerify: true}
-		conn := tls.Client(tcpConn, config)
 		wire.Input = conn
 		wire.Output = conn
 
@@ -95,7 +106,7 @@ func (wire *Wire) SetPrefix(prefix string, color Color) {
 	} else {
 		wire.prefix = []byte(escapeCode(color) + prefix + resetEscapeCode())
 	}
-	wire.Write(wire.prefix) //flush first prefix
+	wire.write(wire.prefix) //flush first prefix, not counted as written
 }
 
 //tell whether or not the stream is interactive
@@ -116,6 +127,20 @@ func (wire *Wire) Terminal() bool {
 }
 
 func (wire *Wire) Write(p []byte) (int, error) {
+	n, err := wire.write(p)
+	if n > 0 && n <= len(p) {
+		atomic.AddUint64(&wire.written, uint64(n))
+		atomic.AddUint64(&wire.lines, uint64(bytes.Count(p[:n], []byte("\n"))))
+	}
+	return n, err
+}
+
+// Written returns the number of bytes and lines written through the wire, prefixes excluded
+func (wire *Wire) Written() (n, lines uint64) {
+	return atomic.LoadUint64(&wire.written), atomic.LoadUint64(&wire.lines)
+}
+
+func (wire *Wire) write(p []byte) (int, error) {
 	if len(wire.prefix) == 0 || !strings.HasSuffix(string(p), "\n") {
 		return wire.Output.Write(p)
 	}
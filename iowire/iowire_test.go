@@ -2,9 +2,14 @@ package iowire
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -65,6 +70,32 @@ func Test_remoteWire(t *testing.T) {
 	wg.Wait()
 }
 
+func Test_tlsWire(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	uri := "tls://" + strings.TrimPrefix(server.URL, "https://")
+
+	//server certificate is self signed
+	if _, err := NewWire(uri); err == nil {
+		t.Fatal("expected the server certificate to be rejected")
+	}
+
+	cert, err := x509.ParseCertificate(server.TLS.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	wire, err := NewWireWithTLS(uri, &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wire.Close()
+	if !wire.Interactive() {
+		t.Fatal("expected a tls wire to be interactive")
+	}
+}
+
 func Test_fileWire(t *testing.T) {
 	wire, err := NewWire("file:///tmp/dock_test.log")
 	if err != nil {
@@ -84,3 +115,22 @@ func Test_fileWire(t *testing.T) {
 		t.Fatalf("expecting \"prefix foo bar\" got \"%s\"", string(content))
 	}
 }
+
+func Test_written(t *testing.T) {
+	wire, err := NewWire("file:///tmp/dock_test_written.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("/tmp/dock_test_written.log")
+	wire.SetPrefix("prefix ", NoColor)
+
+	for _, s := range []string{"foo\n", "bar\nbaz", "\n"} {
+		wire.Write([]byte(s))
+	}
+	wire.Close()
+
+	n, lines := wire.Written()
+	if n != 12 || lines != 3 {
+		t.Fatalf("expected 12 bytes and 3 lines written, got %d bytes and %d lines", n, lines)
+	}
+}
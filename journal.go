@@ -0,0 +1,12 @@
+package main
+
+import "github.com/robinmonjo/dock/journal"
+
+var events *journal.Journal //nil if no journal is kept (see --journal)
+
+// append the event to the journal, if any
+func record(e *journal.Event) {
+	if err := events.Record(e); err != nil {
+		journalLog.Error(err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package journal appends the significant events of a dock run to a JSON lines file, to
+// understand after the fact why a process died without running dock in debug mode
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robinmonjo/dock/notifier"
+)
+
+// event types
+const (
+	TypeState          = "state"            //the process changed state (see notifier.Lifecycle)
+	TypeSignal         = "signal"           //a signal was received and forwarded to the process
+	TypeEscalation     = "escalation"       //a stopping signal was translated into SIGKILL (see --thug)
+	TypeOrphan         = "orphan"           //an orphaned process was reaped
+	TypeRotation       = "rotation"         //the logs were rotated
+	TypeWebHookFailure = "web-hook-failure" //a notification couldn't be delivered
+)
+
+// maximum length of the last line read to resume the sequence
+const tailSize = 64 * 1024
+
+// Event is a line of the journal, fields that don't apply to its type are omitted
+type Event struct {
+	Seq       uint64            `json:"seq"` //increasing, continued across dock runs
+	Time      time.Time         `json:"time"`
+	Type      string            `json:"type"`
+	State     notifier.PsStatus `json:"state,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Pid       int               `json:"pid,omitempty"`
+	Signal    string            `json:"signal,omitempty"`    //signal received
+	Forwarded string            `json:"forwarded,omitempty"` //signal sent to the process
+	Exit      *notifier.Exit    `json:"exit,omitempty"`
+	Archive   string            `json:"archive,omitempty"` //log archive of a rotation
+	Target    string            `json:"target,omitempty"`  //notification target
+	Error     string            `json:"error,omitempty"`
+}
+
+// Journal appends events to a file
+type Journal struct {
+	mutex sync.Mutex
+	file  *os.File
+	seq   uint64
+}
+
+// Open opens the journal for appending, its sequence continues from the last event recorded
+func Open(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	seq, terminated, err := lastSeq(f)
+	if err == nil && !terminated {
+		// a partial line was left (i.e. dock was killed while writing it), don't append to it
+		_, err = f.Write([]byte("\n"))
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Journal{file: f, seq: seq}, nil
+}
+
+// Record sets the sequence number and time of the event and appends it. It's a no-op on a nil
+// journal, so that callers don't have to check whether a journal is kept
+func (j *Journal) Record(e *Event) error {
+	if j == nil {
+		return nil
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.seq++
+	e.Seq = j.seq
+	e.Time = time.Now().UTC()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(b, '\n'))
+	return err
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// sequence number of the last complete event of the file, 0 if there is none. terminated tells
+// whether the file is empty or ends with a new line
+func lastSeq(f *os.File) (seq uint64, terminated bool, err error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	offset := fi.Size() - tailSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, fi.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return 0, false, err
+	}
+	terminated = len(tail) == 0 || tail[len(tail)-1] == '\n'
+
+	lines := bytes.Split(tail, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var e Event
+		if json.Unmarshal(lines[i], &e) == nil && e.Seq > 0 {
+			return e.Seq, terminated, nil
+		}
+	}
+	return 0, terminated, nil
+}
@@ -0,0 +1,85 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robinmonjo/dock/notifier"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.jsonl")
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Record(&Event{Type: TypeState, State: notifier.StatusStarting})
+	j.Record(&Event{Type: TypeSignal, Signal: "SIGTERM", Forwarded: "SIGKILL"})
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// dock was killed while writing an event, the next run continues the sequence on a new line
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"seq":3,"ti`))
+	f.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Record(&Event{Type: TypeOrphan, Pid: 12, Exit: &notifier.Exit{Pid: 12, Status: 1}})
+	j.Close()
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events := []*Event{}
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			if scanner.Text() == `{"seq":3,"ti` {
+				continue
+			}
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, &e)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	for i, e := range events {
+		if e.Seq != uint64(i+1) || e.Time.IsZero() {
+			t.Fatalf("unexpected event %d: %#v", i, e)
+		}
+	}
+	if events[1].Signal != "SIGTERM" || events[1].Forwarded != "SIGKILL" || events[2].Exit == nil || events[2].Exit.Status != 1 {
+		t.Fatalf("unexpected events %#v %#v", events[1], events[2])
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	var j *Journal
+	if err := j.Record(&Event{Type: TypeState}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
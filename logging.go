@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// formats of dock's own logs (see --log-format), the process output isn't affected
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// loggers of dock's components. Entries carry a stable component field, and when relevant pid
+// (of the process concerned), signal and state fields
+var (
+	supervisorLog = componentLog("supervisor")
+	signalsLog    = componentLog("signals")
+	portLog       = componentLog("port")
+	watchdogLog   = componentLog("watchdog")
+	heartbeatLog  = componentLog("heartbeat")
+	webHookLog    = componentLog("web-hook")
+	oomLog        = componentLog("oom")
+	controlLog    = componentLog("control")
+	metricsLog    = componentLog("metrics")
+	journalLog    = componentLog("journal")
+	stateFileLog  = componentLog("state-file")
+)
+
+func componentLog(component string) *log.Entry {
+	return log.WithField("component", component)
+}
+
+// configure dock's own logs, written to stderr unless a file is given
+func setupLogs(debug bool, format, file string) error {
+	if debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	switch format {
+	case logFormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	case logFormatJSON:
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		return fmt.Errorf("invalid log format %q, expected %s or %s", format, logFormatText, logFormatJSON)
+	}
+
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+		if err != nil {
+			return err
+		}
+		log.SetOutput(f) //closed when dock exits
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestSetupLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	std := log.StandardLogger()
+	defer func(out io.Writer, formatter log.Formatter, level log.Level) {
+		log.SetOutput(out)
+		log.SetFormatter(formatter)
+		log.SetLevel(level)
+	}(std.Out, std.Formatter, log.GetLevel())
+
+	tests := []struct {
+		debug  bool
+		format string
+		file   string
+		level  log.Level
+		json   bool
+		valid  bool
+	}{
+		{false, logFormatText, "", log.InfoLevel, false, true},
+		{true, logFormatText, "", log.DebugLevel, false, true},
+		{false, logFormatJSON, "", log.InfoLevel, true, true},
+		{true, logFormatJSON, filepath.Join(dir, "dock.log"), log.DebugLevel, true, true},
+
+		{false, "", "", log.InfoLevel, false, false},
+		{false, "JSON", "", log.InfoLevel, false, false},
+		{false, "logfmt", "", log.InfoLevel, false, false},
+		{false, logFormatText, filepath.Join(dir, "missing", "dock.log"), log.InfoLevel, false, false},
+	}
+
+	for _, test := range tests {
+		log.SetLevel(log.InfoLevel)
+		log.SetOutput(os.Stderr)
+
+		err := setupLogs(test.debug, test.format, test.file)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%+v: expected an error", test)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: %v", test, err)
+			continue
+		}
+
+		if level := log.GetLevel(); level != test.level {
+			t.Errorf("%+v: expected level %v, got %v", test, test.level, level)
+		}
+		if _, json := std.Formatter.(*log.JSONFormatter); json != test.json {
+			t.Errorf("%+v: unexpected formatter %T", test, std.Formatter)
+		}
+		if test.file != "" {
+			f, ok := std.Out.(*os.File)
+			if !ok || f.Name() != test.file {
+				t.Errorf("%+v: expected logs to be written to %s, got %v", test, test.file, std.Out)
+				continue
+			}
+			f.Close()
+			info, err := os.Stat(test.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0640 {
+				t.Errorf("%+v: expected mode 0640, got %v", test, perm)
+			}
+		}
+	}
+}
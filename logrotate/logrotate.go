@@ -9,6 +9,8 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -17,16 +19,22 @@ import (
 var (
 	rotationDelay      = 6 * time.Hour //6 hours by default
 	archiveRetainCount = 5             //number of log archive to keep
+
+	rotateLog = log.WithField("component", "log-rotation")
 )
 
 type Rotator struct {
+	rotations uint64 //first for 64 bits alignment of atomic operations
+
 	LogFile            string
 	RotationDelay      time.Duration
 	ArchiveRetainCount int
+	OnRotate           func(archive string) //called after each rotation, if set
 	ticker             *time.Ticker
 
 	workingDir string
 	watching   bool
+	mutex      sync.Mutex //rotations may be triggered while watching (see Rotate)
 }
 
 func NewRotator(logFile string) *Rotator {
@@ -97,6 +105,27 @@ func (r *Rotator) relatedGZFiles() ([]string, error) {
 	return relatedFiles, nil
 }
 
+// Rotate archives the log file now and removes the oldest archives. It returns the archive path
+func (r *Rotator) Rotate() (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	archive, err := r.gzipLogFile()
+	if err != nil {
+		return "", err
+	}
+	atomic.AddUint64(&r.rotations, 1)
+	if r.OnRotate != nil {
+		r.OnRotate(archive)
+	}
+	return archive, r.cleanupOldArchives()
+}
+
+// Rotations returns the number of rotations performed
+func (r *Rotator) Rotations() uint64 {
+	return atomic.LoadUint64(&r.rotations)
+}
+
 func (r *Rotator) StartWatching() {
 	r.watching = true
 	r.ticker = time.NewTicker(r.RotationDelay)
@@ -105,12 +134,9 @@ func (r *Rotator) StartWatching() {
 		if !r.watching {
 			return
 		}
-		log.Debug("Log rotation: rotating")
-		if _, err := r.gzipLogFile(); err != nil {
-			log.Errorf("Log rotation: %v", err)
-		}
-		if err := r.cleanupOldArchives(); err != nil {
-			log.Errorf("Log rotation: %v", err)
+		rotateLog.Debug("rotating")
+		if _, err := r.Rotate(); err != nil {
+			rotateLog.Error(err)
 		}
 	}
 }
@@ -103,6 +103,39 @@ func Test_cleanupOldArchives(t *testing.T) {
 	}
 }
 
+func Test_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logFile := path.Join(dir, "process.log")
+	if err := ioutil.WriteFile(logFile, []byte("foo bar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRotator(logFile)
+	var rotated string
+	r.OnRotate = func(archive string) { rotated = archive }
+	archive, err := r.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated != archive {
+		t.Fatalf("expected OnRotate to be called with %s, got %q", archive, rotated)
+	}
+	if _, err := os.Stat(archive); err != nil {
+		t.Fatalf("expected archive %s to exist: %v", archive, err)
+	}
+	if cont, _ := ioutil.ReadFile(logFile); len(cont) != 0 {
+		t.Fatalf("log file not empty: %s", string(cont))
+	}
+	if r.Rotations() != 1 {
+		t.Fatalf("expected 1 rotation, got %d", r.Rotations())
+	}
+}
+
 func Test_StartWatching(t *testing.T) {
 	logFile, err := ioutil.TempFile("", "psdock_")
 	if err != nil {
@@ -2,11 +2,18 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
+	"github.com/robinmonjo/dock/control"
+	"github.com/robinmonjo/dock/health"
+	"github.com/robinmonjo/dock/journal"
+	"github.com/robinmonjo/dock/metrics"
 	"github.com/robinmonjo/dock/notifier"
 	"github.com/robinmonjo/dock/port"
 	"github.com/robinmonjo/dock/procfs"
@@ -17,8 +24,13 @@ import (
 
 var (
 	version string //injected by the makefile
+
+	hooks     *notifier.Dispatcher //nil if no web hook is specified
+	lifecycle *notifier.Lifecycle
 )
 
+const defaultFlushTimeout = 10 * time.Second
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "dock"
@@ -28,27 +40,59 @@ func main() {
 	app.Usage = "micro init system for containers"
 
 	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "config", Usage: "JSON or TOML (.toml) file where options are read, named after the flags (see README)"},
 		cli.StringFlag{Name: "io", Usage: "smart stdin / stdout (see README for more info)"},
-		cli.StringFlag{Name: "web-hook", Usage: "hook where process status changes should be notified"},
+		cli.StringSliceFlag{Name: "web-hook", Value: &cli.StringSlice{}, Usage: "http(s), exec, file, unix or statsd URL where process status changes should be notified (format: <url>[;events=ready,exited][;option=value ...]), may be repeated"},
+		cli.StringFlag{Name: "web-hook-secret-file", Usage: "file containing the secret used to sign web hook payloads (HMAC-SHA256), $DOCK_WEB_HOOK_SECRET is used if not set"},
+		cli.StringSliceFlag{Name: "web-hook-header", Value: &cli.StringSlice{}, Usage: "extra header sent with web hook payloads (format: \"Name: value\"), may be repeated"},
+		cli.StringFlag{Name: "web-hook-spool", Usage: "directory where web hook payloads are stored until delivered, so they survive dock restarts"},
+		cli.StringFlag{Name: "web-hook-flush-timeout", Value: defaultFlushTimeout.String(), Usage: "time given to deliver queued web hook payloads when dock exits"},
+		cli.BoolFlag{Name: "web-hook-addressed-interfaces", Usage: "omit network interfaces without address from web hook payloads"},
+		cli.StringFlag{Name: "heartbeat", Usage: "interval between alive notifications reporting the process uptime and resources (i.e. 30s)"},
 		cli.StringFlag{Name: "bind-port", Usage: "port the process is expected to bind"},
 		cli.BoolFlag{Name: "strict-port-binding", Usage: "when bind-port is specified, ensure binding PID is a descendant of dock (see doc for more info)"},
 		cli.IntFlag{Name: "log-rotate", Usage: "duration in hour when stdoud should rotate (if `--io` is a file)"},
 		cli.StringFlag{Name: "stdout-prefix", Usage: "add a prefix to stdout lines (format: <prefix>:<color>)"},
 		cli.BoolFlag{Name: "debug, d", Usage: "run with verbose output (for developpers)"},
+		cli.StringFlag{Name: "log-format", Value: logFormatText, Usage: "format of dock's own logs: text or json"},
+		cli.StringFlag{Name: "log-file", Usage: "file where dock's own logs are written (stderr by default), the process output isn't affected"},
 		cli.BoolFlag{Name: "thug", Usage: "translate stopping signals in SIGKILL if process ignore or block the signal"},
+		cli.StringFlag{Name: "control-socket", Usage: "unix socket where a JSON API to query and control the process is served (i.e. " + control.DefaultSocket + ")"},
+		cli.StringFlag{Name: "metrics-addr", Usage: "address where Prometheus metrics are served on /metrics (i.e. :9102)"},
+		cli.StringFlag{Name: "health-addr", Usage: "address where /healthz (liveness) and /readyz (readiness) are served (i.e. :8086)"},
+		cli.StringFlag{Name: "state-file", Usage: "file where the state of the process is written as JSON on every transition (i.e. /run/dock/state.json)"},
+		cli.StringFlag{Name: "journal", Usage: "file where lifecycle events (states, signals, reaped orphans, rotations, web hook failures) are appended as JSON lines"},
+		cli.StringFlag{Name: "init-dir", Value: defaultInitDir, Usage: "directory whose executables are run in name order before the process starts, ignored if missing"},
+		cli.StringSliceFlag{Name: "init-command", Value: &cli.StringSlice{}, Usage: "command run before the process starts, after the init directory executables, may be repeated"},
+		cli.StringFlag{Name: "init-timeout", Value: defaultInitTimeout.String(), Usage: "time an init executable or command may run before it's killed and the startup aborted (0 for no limit)"},
+		cli.StringFlag{Name: "exit-report", Usage: "file where a JSON report of the process exit and reaped orphans is written when dock exits"},
+		cli.StringFlag{Name: "max-rss", Usage: "resident memory the process tree may use (i.e. 512M, 1G) before the watchdog acts"},
+		cli.IntFlag{Name: "max-cpu-seconds", Usage: "CPU time in seconds the process tree may use before the watchdog acts"},
+		cli.StringFlag{Name: "watchdog-interval", Value: defaultWatchdogInterval.String(), Usage: "interval between two watchdog checks"},
+		cli.StringFlag{Name: "watchdog-action", Value: "TERM", Usage: "signal sent to the process when a watchdog threshold is exceeded, or \"restart\""},
+	}
+
+	app.Flags = append(app.Flags, tlsFlags("io", "io wire")...)
+	app.Flags = append(app.Flags, tlsFlags("web-hook", "web hook")...)
+	app.Flags = withEnv(app.Flags)
+	configOptions = fileOptions(app.Flags)
+
+	app.Commands = commands
+	if err := configure(app, os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
 
 	app.Action = func(c *cli.Context) {
 
-		if c.Bool("debug") {
-			log.SetLevel(log.DebugLevel)
+		if err := setupLogs(c.Bool("debug"), c.String("log-format"), c.String("log-file")); err != nil {
+			log.Fatal(err)
 		}
 
 		exit, err := start(c)
 		if err != nil {
-			log.Error(err)
+			supervisorLog.Error(err)
 		}
-		log.Debugf("exit status: %d", exit)
+		supervisorLog.Debugf("exit status: %d", exit)
 		os.Exit(exit)
 	}
 
@@ -63,9 +107,53 @@ func start(c *cli.Context) (int, error) {
 		return 0, nil
 	}
 
-	log.Debugf("dock pid: %d", os.Getpid())
+	supervisorLog.WithField("pid", os.Getpid()).Debug("dock started")
+
+	var watch *watchdog
+	if c.String("max-rss") != "" || c.Int("max-cpu-seconds") > 0 {
+		w, err := newWatchdog(c.String("max-rss"), c.Int("max-cpu-seconds"), c.String("watchdog-interval"), c.String("watchdog-action"))
+		if err != nil {
+			return 1, err
+		}
+		watch = w
+	}
 
-	wire, err := iowire.NewWire(c.String("io"))
+	var beat *heartbeat
+	if c.String("heartbeat") != "" {
+		if len(c.StringSlice("web-hook")) == 0 {
+			return 1, fmt.Errorf("--heartbeat requires --web-hook")
+		}
+		h, err := newHeartbeat(c.String("heartbeat"))
+		if err != nil {
+			return 1, err
+		}
+		beat = h
+	}
+
+	steps, err := initSteps(c.String("init-dir"), c.StringSlice("init-command"))
+	if err != nil {
+		return 1, err
+	}
+	initTimeout, err := time.ParseDuration(c.String("init-timeout"))
+	if err != nil {
+		return 1, err
+	}
+
+	if path := c.String("journal"); path != "" {
+		j, err := journal.Open(path)
+		if err != nil {
+			return 1, err
+		}
+		events = j
+		defer events.Close()
+	}
+
+	ioTLS, err := tlsConfig(c, "io")
+	if err != nil {
+		return 1, err
+	}
+
+	wire, err := iowire.NewWireWithTLS(c.String("io"), ioTLS)
 	if err != nil {
 		return 1, err
 	}
@@ -74,51 +162,180 @@ func start(c *cli.Context) (int, error) {
 	wire.SetPrefix(parsePrefixArg(c.String("stdout-prefix")))
 
 	process := &process{
-		argv: c.Args(),
-		wire: wire,
+		argv:     c.Args(),
+		wire:     wire,
+		bindPort: c.String("bind-port"),
 	}
 	defer process.cleanup()
 
 	sh := newSignalsHandler()
 	sh.authority = c.Bool("thug")
+	notifier.StartCommand = sh.start
 
-	wh := c.String("web-hook")
-	notifier.WebHook = wh
+	notifier.DockVersion = version
+	notifier.AddressedNetInterfacesOnly = c.Bool("web-hook-addressed-interfaces")
 
-	processStateChanged(notifier.StatusStarting)
-	defer processStateChanged(notifier.StatusCrashed)
+	if len(c.StringSlice("web-hook")) > 0 {
+		flushTimeout, err := time.ParseDuration(c.String("web-hook-flush-timeout"))
+		if err != nil {
+			return 1, err
+		}
+		if hooks, err = newHooks(c); err != nil {
+			return 1, err
+		}
+		defer func() {
+			if err := hooks.Close(flushTimeout); err != nil {
+				webHookLog.Error(err)
+			}
+		}()
+	}
 
-	if err := process.start(); err != nil {
-		return exitStatusFromError(err), err
+	var state *stateFile
+	if path := c.String("state-file"); path != "" {
+		if state, err = newStateFile(path); err != nil {
+			return 1, err
+		}
 	}
 
-	log.Debugf("process pid: %d", process.pid())
+	lifecycle = notifier.NewLifecycle(func(ps *notifier.Ps) {
+		fields := log.Fields{"state": ps.Status}
+		if pid := process.runningPid(); pid != 0 {
+			fields["pid"] = pid
+		} else if ps.Exit != nil {
+			fields["pid"] = ps.Exit.Pid
+		}
+		supervisorLog.WithFields(fields).Debug("process state changed")
+		record(&journal.Event{
+			Type:    journal.TypeState,
+			State:   ps.Status,
+			Message: ps.Message,
+			Pid:     process.runningPid(),
+			Exit:    ps.Exit,
+		})
+		if state != nil {
+			if err := state.write(process, ps); err != nil {
+				stateFileLog.Error(err)
+			}
+		}
+		notify(process, ps)
+	})
+
+	var finalExit *exit
+	defer func() {
+		if path := c.String("exit-report"); path != "" {
+			if err := writeExitReport(path, finalExit, sh.reaped); err != nil {
+				supervisorLog.Error(err)
+			}
+		}
+	}()
+
+	oom := newOOMWatcher()
 
-	// log rotation is specified and if stdout redirecto to a file
-	if c.Int("log-rotate") > 0 && wire.URL.Scheme == "file" {
-		r := logrotate.NewRotator(wire.URL.Host + wire.URL.Path)
-		r.RotationDelay = time.Duration(c.Int("log-rotate")) * time.Hour
-		go r.StartWatching()
-		defer r.StopWatching()
+	// logs may be rotated if stdout is redirected to a file, periodically if log rotation is specified
+	var rotator *logrotate.Rotator
+	if wire.URL.Scheme == "file" {
+		rotator = logrotate.NewRotator(wire.URL.Host + wire.URL.Path)
+		rotator.OnRotate = func(archive string) {
+			record(&journal.Event{Type: journal.TypeRotation, Archive: archive})
+		}
+		if c.Int("log-rotate") > 0 {
+			rotator.RotationDelay = time.Duration(c.Int("log-rotate")) * time.Hour
+			go rotator.StartWatching()
+			defer rotator.StopWatching()
+		}
 	}
 
-	// watch ports
-	go func() {
-		bindPort := c.String("bind-port")
-		if bindPort != "" {
-			waitPortBinding(bindPort, c.Bool("strict-port-binding"))
-		} else {
-			processStateChanged(notifier.StatusRunning)
+	if path := c.String("control-socket"); path != "" {
+		server, err := control.Listen(path, &supervisor{process: process, rotator: rotator})
+		if err != nil {
+			return 1, err
 		}
-	}()
+		defer server.Close()
+	}
+
+	if addr := c.String("metrics-addr"); addr != "" {
+		registerMetrics(process, wire, rotator)
+		server, err := metrics.Listen(addr, registry)
+		if err != nil {
+			return 1, err
+		}
+		defer server.Close()
+	}
+
+	if addr := c.String("health-addr"); addr != "" {
+		server, err := health.Listen(addr, func() *health.Check { return healthCheck(process) })
+		if err != nil {
+			return 1, err
+		}
+		defer server.Close()
+	}
+
+	if err := runInit(steps, wire, initTimeout, sh); err != nil {
+		processStateChanged(&notifier.Ps{Status: notifier.StatusFailedToStart, Message: err.Error()})
+		return 1, err
+	}
+
+	if wire.Interactive() {
+		go process.watchWire()
+	}
+
+	var e exit
+	for restarts := 0; ; restarts++ {
+		if restarts > 0 {
+			supervisorLog.Debugf("restarting process (restart #%d)", restarts)
+			process.cleanup()
+		}
+		processStateChanged(&notifier.Ps{Status: notifier.StatusStarting})
+
+		if err := process.start(); err != nil {
+			processStateChanged(&notifier.Ps{Status: notifier.StatusFailedToStart, Message: err.Error()})
+			return exitStatusFromError(err), err
+		}
+
+		supervisorLog.WithField("pid", process.pid()).Debug("process started")
+
+		// stop per process watchers once the process exits
+		stop := make(chan bool)
+
+		// watch ports
+		go func() {
+			if process.bindPort != "" {
+				waitPortBinding(process, c.Bool("strict-port-binding"), stop)
+			} else {
+				processStateChanged(&notifier.Ps{Status: notifier.StatusReady})
+			}
+		}()
+
+		if watch != nil {
+			go watch.watch(process, stop)
+		}
+
+		if beat != nil {
+			go beat.beat(process, stop)
+		}
+
+		e = sh.forward(process) //blocking call
+		close(stop)
+		process.exited()
+		ps := e.state(oom)
+		exitsTotal.With(string(ps.Status), strconv.Itoa(e.status)).Inc()
+		processStateChanged(ps)
+
+		if !process.restartRequested() {
+			break
+		}
+		process.restarting()
+		processStateChanged(&notifier.Ps{Status: notifier.StatusRestarting})
+	}
 
-	exit := sh.forward(process) //blocking call
+	finalExit = &e
+	exit := e.status
 
 	if c.Bool("debug") {
 		//assert, at this point only 1 process should be running, self
 		i, err := procfs.CountRunningProcs()
 		if err != nil {
-			log.Error(err)
+			supervisorLog.Error(err)
 		} else {
 			if i != 1 {
 				exit = 999
@@ -128,15 +345,83 @@ func start(c *cli.Context) (int, error) {
 	return exit, nil
 }
 
-func processStateChanged(state notifier.PsStatus) {
-	log.Debugf("process state: %q", state)
-	if notifier.WebHook != "" {
-		notifier.NotifyHook(state)
+// move the process to ps.Status, transitions not allowed by the lifecycle are ignored
+func processStateChanged(ps *notifier.Ps) {
+	if err := lifecycle.Transition(ps); err != nil {
+		supervisorLog.WithField("state", ps.Status).Debug(err)
 	}
 }
 
-func waitPortBinding(watchedPort string, strictBinding bool) {
+// notification targets given with --web-hook (format: <url>[;option=value ...]). Web hook options
+// (secret, headers, TLS) apply to every http and https target, headers given in a target
+// options take precedence
+func newHooks(c *cli.Context) (*notifier.Dispatcher, error) {
+	headers, err := notifier.ParseHeaders(c.StringSlice("web-hook-header"))
+	if err != nil {
+		return nil, err
+	}
+
+	hookTLS, err := tlsConfig(c, "web-hook")
+	if err != nil {
+		return nil, err
+	}
+
+	secret := os.Getenv("DOCK_WEB_HOOK_SECRET")
+	if secretFile := c.String("web-hook-secret-file"); secretFile != "" {
+		b, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = strings.TrimRight(string(b), "\r\n")
+		if secret == "" {
+			return nil, fmt.Errorf("web hook secret file %s is empty", secretFile)
+		}
+	}
+
+	d := &notifier.Dispatcher{
+		OnFailure: func(t *notifier.Target, err error) {
+			record(&journal.Event{Type: journal.TypeWebHookFailure, Target: t.URL, Error: err.Error()})
+		},
+	}
+	for _, spec := range c.StringSlice("web-hook") {
+		t, err := notifier.ParseTarget(spec)
+		if err != nil {
+			return nil, err
+		}
+		if hook, ok := t.Notifier.(*notifier.Hook); ok {
+			for name, values := range headers {
+				if _, ok := hook.Headers[name]; !ok {
+					hook.Headers[name] = values
+				}
+			}
+			hook.Secret = []byte(secret)
+			hook.TLSConfig = hookTLS
+			hook.Client = hook.NewClient()
+		}
+
+		if err := d.Add(t, c.String("web-hook-spool")); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func notify(p *process, ps *notifier.Ps) {
+	if hooks != nil {
+		p.describe(ps)
+		hooks.Notify(ps)
+	}
+}
+
+func waitPortBinding(process *process, strictBinding bool, stop <-chan bool) {
+	watchedPort := process.bindPort
 	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
 		p := procfs.Self()
 
 		pids := []int{}
@@ -144,25 +429,26 @@ func waitPortBinding(watchedPort string, strictBinding bool) {
 		if strictBinding {
 			descendants, err := p.Descendants()
 			if err != nil {
-				log.Error(err)
+				portLog.Error(err)
 				break
 			}
 
 			for _, p := range descendants {
 				pids = append(pids, p.Pid)
 			}
-			log.Debug(pids)
+			portLog.Debug(pids)
 		}
 
 		binderPid, err := port.IsPortBound(watchedPort, pids)
 		if err != nil {
-			log.Error(err)
+			portLog.Error(err)
 			break
 		}
-		log.Debug(binderPid)
+		portLog.Debug(binderPid)
 		if binderPid != -1 {
-			log.Debugf("port %s binded by pid %d (used strict check: %v)", watchedPort, binderPid, strictBinding)
-			processStateChanged(notifier.StatusRunning)
+			portLog.WithField("pid", binderPid).Debugf("port %s binded (used strict check: %v)", watchedPort, strictBinding)
+			process.portBound(binderPid)
+			processStateChanged(&notifier.Ps{Status: notifier.StatusReady})
 			break
 		}
 		time.Sleep(200 * time.Millisecond)
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/robinmonjo/dock/iowire"
+	"github.com/robinmonjo/dock/logrotate"
+	"github.com/robinmonjo/dock/metrics"
+	"github.com/robinmonjo/dock/notifier"
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// metrics served with --metrics-addr. Counters are maintained even if metrics aren't served
+var (
+	registry = metrics.NewRegistry()
+
+	exitsTotal       = registry.CounterVec("dock_process_exits_total", "Exits of the process by state (exited, killed, oom-killed) and exit status.", "state", "status")
+	signalsForwarded = registry.CounterVec("dock_signals_forwarded_total", "Signals forwarded to the process by name, after translation (see --thug).", "signal")
+	orphansReaped    = registry.Counter("dock_orphans_reaped_total", "Orphaned processes reaped by dock.")
+)
+
+// register the metrics read from the process and dock's components when collected
+func registerMetrics(p *process, wire *iowire.Wire, rotator *logrotate.Rotator) {
+	registry.GaugeFunc("dock_process_up", "Whether the process is running.", func() float64 {
+		return boolValue(p.runningPid() != 0)
+	})
+	registry.GaugeFunc("dock_process_ready", "Whether the process is ready (see --bind-port).", func() float64 {
+		return boolValue(lifecycle.State() == notifier.StatusReady)
+	})
+	registry.GaugeFunc("dock_process_uptime_seconds", "Time since the process started, 0 if it isn't running.", func() float64 {
+		return p.uptime().Seconds()
+	})
+	registry.CounterFunc("dock_process_restarts_total", "Number of times the process was restarted.", func() float64 {
+		ps := &notifier.Ps{}
+		p.describe(ps)
+		return float64(ps.Restarts)
+	})
+
+	registry.CounterFunc("dock_io_written_bytes_total", "Bytes written by the process through --io.", func() float64 {
+		n, _ := wire.Written()
+		return float64(n)
+	})
+	registry.CounterFunc("dock_io_written_lines_total", "Lines written by the process through --io.", func() float64 {
+		_, lines := wire.Written()
+		return float64(lines)
+	})
+	registry.CounterFunc("dock_log_rotations_total", "Log rotations performed (see --log-rotate).", func() float64 {
+		if rotator == nil {
+			return 0
+		}
+		return float64(rotator.Rotations())
+	})
+	registry.Collect("dock_web_hook_deliveries_total", "Web hook delivery attempts by result (success or failure).", metrics.CounterType, func() []metrics.Sample {
+		var delivered, failed uint64
+		if hooks != nil {
+			delivered, failed = hooks.Stats()
+		}
+		return []metrics.Sample{
+			{Labels: metrics.Labels{"result": "success"}, Value: float64(delivered)},
+			{Labels: metrics.Labels{"result": "failure"}, Value: float64(failed)},
+		}
+	})
+
+	registry.Collect("dock_descendant_resident_memory_bytes", "Resident memory of dock's descendants, by process.", metrics.GaugeType, func() []metrics.Sample {
+		return descendantSamples(func(stat *procfs.ProcStat) float64 {
+			return float64(uint64(stat.RSS) * uint64(os.Getpagesize()))
+		})
+	})
+	registry.Collect("dock_descendant_cpu_seconds_total", "CPU time (user and system) of dock's descendants, by process.", metrics.CounterType, func() []metrics.Sample {
+		return descendantSamples(func(stat *procfs.ProcStat) float64 {
+			return procfs.TicksToDuration(stat.UTime + stat.STime).Seconds()
+		})
+	})
+}
+
+// one sample per descendant, labeled with its pid and name
+func descendantSamples(value func(stat *procfs.ProcStat) float64) []metrics.Sample {
+	descendants, err := procfs.Self().Descendants()
+	if err != nil {
+		metricsLog.Debug(err)
+		return nil
+	}
+	samples := []metrics.Sample{}
+	for _, d := range descendants {
+		stat, err := d.Stat()
+		if err != nil {
+			continue //process exited meanwhile
+		}
+		samples = append(samples, metrics.Sample{
+			Labels: metrics.Labels{"pid": strconv.Itoa(d.Pid), "name": stat.Comm},
+			Value:  value(stat),
+		})
+	}
+	return samples
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,278 @@
+// Package metrics exposes counters and gauges in the Prometheus text format
+// (https://prometheus.io/docs/instrumenting/exposition_formats/), without client library
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ContentType of the text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	CounterType = "counter"
+	GaugeType   = "gauge"
+)
+
+// Labels of a sample, rendered sorted by name
+type Labels map[string]string
+
+// Sample is a value of a metric, labels make it unique among the samples of the metric
+type Sample struct {
+	Labels Labels
+	Value  float64
+}
+
+// Registry holds metrics, collected each time they are written
+type Registry struct {
+	mutex   sync.Mutex
+	metrics map[string]*metric
+}
+
+type metric struct {
+	help    string
+	typ     string
+	collect func() []Sample
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]*metric{}}
+}
+
+// Collect registers a metric whose samples are returned by collect when written, i.e. values
+// read from procfs. It panics if the name is already registered
+func (r *Registry) Collect(name, help, typ string, collect func() []Sample) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.metrics[name] = &metric{help: help, typ: typ, collect: collect}
+}
+
+// CounterFunc registers a counter whose value is returned by f, i.e. a counter maintained by
+// another package
+func (r *Registry) CounterFunc(name, help string, f func() float64) {
+	r.Collect(name, help, CounterType, func() []Sample {
+		return []Sample{{Value: f()}}
+	})
+}
+
+// GaugeFunc registers a gauge whose value is returned by f
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.Collect(name, help, GaugeType, func() []Sample {
+		return []Sample{{Value: f()}}
+	})
+}
+
+// Counter registers a new counter
+func (r *Registry) Counter(name, help string) *Counter {
+	c := &Counter{}
+	r.CounterFunc(name, help, func() float64 { return float64(c.Value()) })
+	return c
+}
+
+// CounterVec registers a new counter partitioned by the given labels
+func (r *Registry) CounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{labels: labels, counters: map[string]*labeledCounter{}}
+	r.Collect(name, help, CounterType, v.samples)
+	return v
+}
+
+// WriteTo writes every metric, sorted by name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.metrics))
+	metrics := make(map[string]*metric, len(r.metrics))
+	for name, m := range r.metrics {
+		names = append(names, name)
+		metrics[name] = m
+	}
+	r.mutex.Unlock()
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	cw := &countWriter{w: buf}
+	for _, name := range names {
+		m := metrics[name]
+		fmt.Fprintf(cw, "# HELP %s %s\n", name, escapeHelp(m.help))
+		fmt.Fprintf(cw, "# TYPE %s %s\n", name, m.typ)
+		for _, s := range m.collect() {
+			fmt.Fprintf(cw, "%s%s %s\n", name, s.Labels, formatValue(s.Value))
+		}
+	}
+	if cw.err == nil {
+		cw.err = buf.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes the metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if _, err := r.WriteTo(w); err != nil {
+		log.WithField("component", "metrics").Debug(err)
+	}
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	value uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// CounterVec is a set of counters with the same name, one per label values
+type CounterVec struct {
+	labels []string
+
+	mutex    sync.Mutex
+	counters map[string]*labeledCounter
+}
+
+type labeledCounter struct {
+	Counter
+	labels Labels
+}
+
+// With returns the counter of the given label values, in the order the labels were registered
+func (v *CounterVec) With(values ...string) *Counter {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	c, ok := v.counters[key]
+	if !ok {
+		c = &labeledCounter{labels: Labels{}}
+		for i, label := range v.labels {
+			c.labels[label] = values[i]
+		}
+		v.counters[key] = c
+	}
+	return &c.Counter
+}
+
+// samples sorted by label values, for a stable output
+func (v *CounterVec) samples() []Sample {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	keys := make([]string, 0, len(v.counters))
+	for key := range v.counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	samples := make([]Sample, 0, len(keys))
+	for _, key := range keys {
+		c := v.counters[key]
+		samples = append(samples, Sample{Labels: c.labels, Value: float64(c.Value())})
+	}
+	return samples
+}
+
+// Server serves the metrics over HTTP on /metrics
+type Server struct {
+	Addr string
+
+	listener net.Listener
+}
+
+// Listen serves the registry in background
+func Listen(addr string, r *Registry) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	go func() {
+		// returns once the listener is closed
+		http.Serve(l, mux)
+	}()
+	return &Server{Addr: l.Addr().String(), listener: l}, nil
+}
+
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// i.e. {pid="7",signal="SIGHUP"}, empty if there are no labels
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, labelValueEscaper.Replace(l[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countWriter counts the bytes written and keeps the first error
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	restarts := r.Counter("dock_restarts_total", "Restarts of the process.")
+	signals := r.CounterVec("dock_signals_total", "Signals\nforwarded.", "signal")
+	r.GaugeFunc("dock_up", "Whether the process is up.", func() float64 { return 1 })
+	r.Collect("dock_rss_bytes", "RSS by process.", GaugeType, func() []Sample {
+		return []Sample{
+			{Labels: Labels{"pid": "7", "name": `a "quoted\" name`}, Value: 1024},
+			{Labels: Labels{"pid": "8", "name": "sleep"}, Value: math.Inf(1)},
+		}
+	})
+
+	restarts.Inc()
+	restarts.Add(2)
+	signals.With("SIGTERM").Inc()
+	signals.With("SIGHUP").Add(3)
+	signals.With("SIGTERM").Inc()
+
+	expected := `# HELP dock_restarts_total Restarts of the process.
+# TYPE dock_restarts_total counter
+dock_restarts_total 3
+# HELP dock_rss_bytes RSS by process.
+# TYPE dock_rss_bytes gauge
+dock_rss_bytes{name="a \"quoted\\\" name",pid="7"} 1024
+dock_rss_bytes{name="sleep",pid="8"} +Inf
+# HELP dock_signals_total Signals\nforwarded.
+# TYPE dock_signals_total counter
+dock_signals_total{signal="SIGHUP"} 3
+dock_signals_total{signal="SIGTERM"} 2
+# HELP dock_up Whether the process is up.
+# TYPE dock_up gauge
+dock_up 1
+`
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("expected %d bytes written, got %d", buf.Len(), n)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("dock_restarts_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering the same name twice to panic")
+		}
+	}()
+	r.GaugeFunc("dock_restarts_total", "", func() float64 { return 0 })
+}
+
+func TestListen(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("dock_restarts_total", "Restarts of the process.").Inc()
+
+	server, err := Listen("127.0.0.1:0", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentType {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !bytes.Contains(body, []byte("\ndock_restarts_total 1\n")) {
+		t.Fatalf("unexpected body %s", body)
+	}
+
+	resp, err = http.Get("http://" + server.Addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 outside /metrics, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,186 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultStatsdPrefix = "dock"
+	maxCommandOutput    = 512 //bytes of a failed command output reported in errors
+)
+
+// StartCommand starts the commands of the exec backend, the returned function waits for them.
+// dock replaces it when it reaps every child process, so that commands exits aren't collected
+// as orphans
+var StartCommand = func(cmd *exec.Cmd) (func() error, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Wait, nil
+}
+
+// Exec runs a command for each payload. The payload is written to the command stdin and its
+// main fields are exported as environment variables (DOCK_EVENT, DOCK_SEQ ...)
+type Exec struct {
+	Argv    []string
+	Timeout time.Duration //10 seconds if 0, the command is killed after the timeout
+}
+
+func (e *Exec) Send(body []byte) error {
+	env, err := payloadEnv(body)
+	if err != nil {
+		return err
+	}
+
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = sendTimeout
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(e.Argv[0], e.Argv[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// the command and its children are killed together on timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	wait, err := StartCommand(cmd)
+	if err == nil {
+		pgid := cmd.Process.Pid
+		timer := time.AfterFunc(timeout, func() {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		err = wait()
+		if !timer.Stop() {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+	}
+	if err != nil {
+		out := strings.TrimSpace(output.String())
+		if len(out) > maxCommandOutput {
+			out = out[:maxCommandOutput] + "..."
+		}
+		if out != "" {
+			return fmt.Errorf("%s: %v: %s", e.Argv[0], err, out)
+		}
+		return fmt.Errorf("%s: %v", e.Argv[0], err)
+	}
+	return nil
+}
+
+// environment variables given to exec commands
+func payloadEnv(body []byte) ([]string, error) {
+	var p HookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	if p.Ps == nil {
+		p.Ps = &Ps{}
+	}
+
+	env := []string{
+		"DOCK_PAYLOAD_VERSION=" + strconv.Itoa(p.Version),
+		"DOCK_SEQ=" + strconv.FormatUint(p.Seq, 10),
+		"DOCK_TIMESTAMP=" + p.Timestamp.Format(time.RFC3339Nano),
+		"DOCK_HOSTNAME=" + p.Hostname,
+		"DOCK_CONTAINER_ID=" + p.ContainerID,
+		"DOCK_EVENT=" + string(p.Ps.Status),
+		"DOCK_MESSAGE=" + p.Ps.Message,
+		"DOCK_PID=" + strconv.Itoa(p.Ps.Pid),
+	}
+	if p.Ps.Exit != nil {
+		env = append(env,
+			"DOCK_EXIT_STATUS="+strconv.Itoa(p.Ps.Exit.Status),
+			"DOCK_EXIT_CODE="+strconv.Itoa(p.Ps.Exit.Code),
+			"DOCK_EXIT_SIGNAL="+p.Ps.Exit.Signal,
+		)
+	}
+	return env, nil
+}
+
+// File appends payloads as JSON lines to a file. The file may be a FIFO, in which case
+// sending fails (and is retried) while no process reads it
+type File struct {
+	Path string
+}
+
+func (f *File) Send(body []byte) error {
+	// O_NONBLOCK: opening a FIFO without reader fails instead of blocking
+	file, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NONBLOCK, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := syscall.SetNonblock(int(file.Fd()), false); err != nil {
+		return err
+	}
+
+	// a single write, so that lines of concurrent writers aren't interleaved
+	_, err = file.Write(append(body, '\n'))
+	return err
+}
+
+// Unix writes each payload as a datagram to a unix socket
+type Unix struct {
+	Path string
+}
+
+func (u *Unix) Send(body []byte) error {
+	conn, err := net.DialTimeout("unixgram", u.Path, sendTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	_, err = conn.Write(body)
+	return err
+}
+
+// Statsd increments the <Prefix>.process.<status> counter for each payload. Tags (i.e.
+// env:prod) are sent using the DogStatsD format
+type Statsd struct {
+	Addr   string
+	Prefix string
+	Tags   []string
+}
+
+func (s *Statsd) Send(body []byte) error {
+	var p HookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return err
+	}
+	if p.Ps == nil {
+		return fmt.Errorf("no process status in payload")
+	}
+
+	conn, err := net.DialTimeout("udp", s.Addr, sendTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(s.metric(p.Ps.Status)))
+	return err
+}
+
+func (s *Statsd) metric(status PsStatus) string {
+	name := "process." + string(status)
+	if s.Prefix != "" {
+		name = s.Prefix + "." + name
+	}
+	metric := name + ":1|c"
+	if len(s.Tags) > 0 {
+		metric += "|#" + strings.Join(s.Tags, ",")
+	}
+	return metric
+}
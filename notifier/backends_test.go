@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func lookPath(t *testing.T, file string) string {
+	path, err := exec.LookPath(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dock-notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func encode(t *testing.T, p *HookPayload) []byte {
+	body, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func killed() *HookPayload {
+	p := payload(7)
+	p.Ps = &Ps{Status: StatusKilled, Pid: 12, Exit: &Exit{Pid: 12, Status: 137, Signal: "SIGKILL"}}
+	return p
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "on-event.sh")
+	out := filepath.Join(dir, "out")
+	content := `#!/bin/sh
+echo "$DOCK_EVENT $DOCK_SEQ $DOCK_PID $DOCK_EXIT_STATUS $DOCK_EXIT_SIGNAL $1" > ` + out + `
+cat >> ` + out + `
+`
+	if err := ioutil.WriteFile(script, []byte(content), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := ParseTarget("exec://" + script + " arg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := encode(t, killed())
+	if err := target.Notifier.Send(body); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(string(b), "\n", 2)
+	if lines[0] != "killed 7 12 137 SIGKILL arg" {
+		t.Fatalf("unexpected environment %q", lines[0])
+	}
+	if lines[1] != string(body) {
+		t.Fatalf("expected payload on stdin, got %q", lines[1])
+	}
+
+	failing := &Exec{Argv: []string{lookPath(t, "sh"), "-c", "echo boom; exit 3"}}
+	if err := failing.Send(body); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected an error with the command output, got %v", err)
+	}
+
+	slow := &Exec{Argv: []string{lookPath(t, "sleep"), "5"}, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	if err := slow.Send(body); err == nil {
+		t.Fatal("expected an error when the command times out")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("command not killed after its timeout (%v)", d)
+	}
+
+	// the background sleep holds the output of the command until it's killed too
+	background := &Exec{Argv: []string{lookPath(t, "sh"), "-c", "sleep 5 & sleep 5"}, Timeout: 50 * time.Millisecond}
+	start = time.Now()
+	if err := background.Send(body); err == nil {
+		t.Fatal("expected an error when the command times out")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("command children not killed after its timeout (%v)", d)
+	}
+}
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	f := &File{Path: filepath.Join(dir, "events.jsonl")}
+	for i := uint64(1); i <= 2; i++ {
+		if err := f.Send(encode(t, payload(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %q", b)
+	}
+	var p HookPayload
+	if err := json.Unmarshal([]byte(lines[1]), &p); err != nil || p.Seq != 2 {
+		t.Fatalf("unexpected line %q (%v)", lines[1], err)
+	}
+
+	// a FIFO without reader
+	fifo := &File{Path: filepath.Join(dir, "events.fifo")}
+	if err := syscall.Mkfifo(fifo.Path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fifo.Send([]byte("{}")); err == nil {
+		t.Fatal("expected an error when no process reads the FIFO")
+	}
+}
+
+func TestUnix(t *testing.T) {
+	t.Parallel()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	u := &Unix{Path: filepath.Join(dir, "events.sock")}
+	if err := u.Send([]byte("{}")); err == nil {
+		t.Fatal("expected an error without listener")
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: u.Path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	body := encode(t, killed())
+	if err := u.Send(body); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != string(body) {
+		t.Fatalf("expected datagram %q, got %q", body, b[:n])
+	}
+}
+
+func TestStatsd(t *testing.T) {
+	t.Parallel()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		statsd   *Statsd
+		expected string
+	}{
+		{&Statsd{Addr: conn.LocalAddr().String(), Prefix: "dock"}, "dock.process.killed:1|c"},
+		{&Statsd{Addr: conn.LocalAddr().String(), Tags: []string{"env:prod", "team:infra"}}, "process.killed:1|c|#env:prod,team:infra"},
+	}
+	for _, test := range tests {
+		if err := test.statsd.Send(encode(t, killed())); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 512)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b[:n]) != test.expected {
+			t.Fatalf("expected metric %q, got %q", test.expected, b[:n])
+		}
+	}
+}
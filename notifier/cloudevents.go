@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// web hook body formats
+const (
+	FormatJSON              = "json"               //dock payload, the default
+	FormatCloudEvents       = "cloudevents"        //CloudEvent in the structured content mode
+	FormatCloudEventsBinary = "cloudevents-binary" //CloudEvent in the binary content mode
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	CloudEventsTypePrefix  = "io.dock.process."
+	cloudEventsContentType = "application/cloudevents+json"
+)
+
+var Formats = []string{FormatJSON, FormatCloudEvents, FormatCloudEventsBinary}
+
+// CloudEvent is a CloudEvents 1.0 event (https://cloudevents.io) whose data is a dock payload
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// NewCloudEvent wraps a JSON encoded payload. The type is io.dock.process.<status>, the source
+// is the given one or /dock/<container id> (/dock/<hostname> outside of containers). The id
+// only depends on the payload, so that retried deliveries can be deduplicated
+func NewCloudEvent(body []byte, source string) (*CloudEvent, error) {
+	var p HookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	if p.Ps == nil {
+		return nil, fmt.Errorf("no process status in payload")
+	}
+
+	if source == "" {
+		source = "/dock/" + p.Hostname
+		if p.ContainerID != "" {
+			source = "/dock/" + p.ContainerID
+		}
+	}
+
+	return &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              fmt.Sprintf("%d-%d", p.Timestamp.UnixNano(), p.Seq),
+		Source:          source,
+		Type:            CloudEventsTypePrefix + string(p.Ps.Status),
+		Time:            p.Timestamp,
+		DataContentType: "application/json",
+		Data:            json.RawMessage(body),
+	}, nil
+}
+
+// encode the event for the given format, returns the request body and content type
+func (e *CloudEvent) encode(format string, header http.Header) ([]byte, string, error) {
+	if format == FormatCloudEventsBinary {
+		// attributes as ce- headers, the data is the body
+		header.Set("Ce-Specversion", e.SpecVersion)
+		header.Set("Ce-Id", e.ID)
+		header.Set("Ce-Source", e.Source)
+		header.Set("Ce-Type", e.Type)
+		header.Set("Ce-Time", e.Time.Format(time.RFC3339Nano))
+		return []byte(e.Data), e.DataContentType, nil
+	}
+	b, err := json.Marshal(e)
+	return b, cloudEventsContentType, err
+}
+
+func validFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewCloudEvent(t *testing.T) {
+	t.Parallel()
+	ts := time.Date(2016, 2, 11, 10, 32, 7, 512000000, time.UTC)
+	body := encode(t, &HookPayload{Version: PayloadVersion, Seq: 3, Timestamp: ts, Hostname: "4a7b5c0e7e3f", Ps: &Ps{Status: StatusReady}})
+
+	e, err := NewCloudEvent(body, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.SpecVersion != "1.0" || e.Type != "io.dock.process.ready" || e.Source != "/dock/4a7b5c0e7e3f" || !e.Time.Equal(ts) {
+		t.Fatalf("unexpected event %#v", e)
+	}
+	if e.ID != "1455186727512000000-3" {
+		t.Fatalf("expected the id to be derived from the payload, got %q", e.ID)
+	}
+
+	body = encode(t, &HookPayload{Seq: 3, Timestamp: ts, Hostname: "4a7b5c0e7e3f", ContainerID: "4a7b5c0e7e3f9d2c", Ps: &Ps{Status: StatusKilled}})
+	if e, err = NewCloudEvent(body, ""); err != nil || e.Source != "/dock/4a7b5c0e7e3f9d2c" {
+		t.Fatalf("expected the container id as source, got %v (%v)", e, err)
+	}
+	if e, err = NewCloudEvent(body, "urn:dock:web"); err != nil || e.Source != "urn:dock:web" {
+		t.Fatalf("expected the given source, got %v (%v)", e, err)
+	}
+
+	if _, err := NewCloudEvent([]byte("{}"), ""); err == nil {
+		t.Fatal("expected an error for a payload without process status")
+	}
+}
+
+func TestHookCloudEvents(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	c := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		c <- request{r.Header, body}
+	}))
+	defer server.Close()
+
+	secret := []byte("s3cr3t")
+	payload := encode(t, NewPayload(&Ps{Status: StatusKilled, Pid: 7}))
+
+	// structured mode: the event is the body
+	hook := &Hook{URL: server.URL, Format: FormatCloudEvents, Secret: secret}
+	if err := hook.Send(payload); err != nil {
+		t.Fatal(err)
+	}
+	r := <-c
+	if ct := r.header.Get("Content-Type"); ct != "application/cloudevents+json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if err := VerifySignature(secret, r.body, r.header.Get(SignatureHeader), time.Minute); err != nil {
+		t.Fatalf("expected the event to be signed: %v", err)
+	}
+	var event struct {
+		CloudEvent
+		Data *HookPayload `json:"data"`
+	}
+	if err := json.Unmarshal(r.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.SpecVersion != "1.0" || event.Type != "io.dock.process.killed" || event.ID == "" || event.Source == "" || event.DataContentType != "application/json" {
+		t.Fatalf("unexpected event %s", r.body)
+	}
+	if event.Data == nil || event.Data.Ps.Pid != 7 {
+		t.Fatalf("expected the payload as data, got %s", r.body)
+	}
+
+	// binary mode: attributes are headers, the payload is the body
+	hook.Format = FormatCloudEventsBinary
+	if err := hook.Send(payload); err != nil {
+		t.Fatal(err)
+	}
+	r = <-c
+	if !bytes.Equal(r.body, payload) || r.header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the payload as body, got %q (%s)", r.header.Get("Content-Type"), r.body)
+	}
+	if r.header.Get("Ce-Specversion") != "1.0" || r.header.Get("Ce-Type") != "io.dock.process.killed" || r.header.Get("Ce-Id") != event.ID || r.header.Get("Ce-Source") != event.Source {
+		t.Fatalf("unexpected ce- headers %v", r.header)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, r.header.Get("Ce-Time")); err != nil {
+		t.Fatalf("invalid Ce-Time header: %v", err)
+	}
+}
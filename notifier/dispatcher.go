@@ -0,0 +1,114 @@
+package notifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dispatcher notifies several targets. Each target has its own queue, so a slow or unreachable
+// target doesn't delay the others
+type Dispatcher struct {
+	OnFailure func(t *Target, err error) //called on each failed delivery attempt, if set
+
+	targets []*queuedTarget
+}
+
+type queuedTarget struct {
+	*Target
+	queue *Queue
+}
+
+// Add starts delivering payloads to the target. If spool is not empty, the target payloads are
+// spooled in a sub directory named after the target specification (URL and options), and its
+// rank among the targets having the same one
+func (d *Dispatcher) Add(t *Target, spool string) error {
+	if spool != "" {
+		sum := sha256.Sum256([]byte(t.Spec))
+		dir := hex.EncodeToString(sum[:6])
+		same := 0
+		for _, other := range d.targets {
+			if other.Spec == t.Spec {
+				same++
+			}
+		}
+		if same > 0 {
+			dir = fmt.Sprintf("%s-%d", dir, same)
+		}
+		spool = filepath.Join(spool, dir)
+	}
+	send := t.Notifier.Send
+	if d.OnFailure != nil {
+		send = func(body []byte) error {
+			err := t.Notifier.Send(body)
+			if err != nil {
+				d.OnFailure(t, err)
+			}
+			return err
+		}
+	}
+	q, err := NewQueue(send, spool)
+	if err != nil {
+		return err
+	}
+	d.targets = append(d.targets, &queuedTarget{Target: t, queue: q})
+	return nil
+}
+
+// Notify queues the process information for the targets subscribed to its status. All targets
+// receive the same payload (and sequence number)
+func (d *Dispatcher) Notify(ps *Ps) {
+	var payload *HookPayload
+	for _, t := range d.targets {
+		if !t.Accepts(ps.Status) {
+			continue
+		}
+		if payload == nil {
+			payload = NewPayload(ps)
+		}
+		t.queue.Push(payload)
+	}
+}
+
+// Stats returns the number of payloads delivered and of failed delivery attempts, all targets
+// included
+func (d *Dispatcher) Stats() (delivered, failed uint64) {
+	for _, t := range d.targets {
+		dd, f := t.queue.Stats()
+		delivered += dd
+		failed += f
+	}
+	return delivered, failed
+}
+
+// Close closes every queue, waiting at most timeout for all of them to be flushed
+func (d *Dispatcher) Close(timeout time.Duration) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(d.targets))
+	for i, t := range d.targets {
+		wg.Add(1)
+		go func(i int, t *queuedTarget) {
+			defer wg.Done()
+			if err := t.queue.Close(timeout); err != nil {
+				errs[i] = fmt.Errorf("%s: %v", t.URL, err)
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	messages := []string{}
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+)
+
+// transitions lists the states reachable from each state, the zero state being the initial one
+var transitions = map[PsStatus][]PsStatus{
+	"":                  {StatusInitializing, StatusStarting},
+	StatusInitializing:  {StatusInitializing, StatusStarting, StatusFailedToStart}, //once per init step
+	StatusStarting:      {StatusReady, StatusUnhealthy, StatusStopping, StatusExited, StatusKilled, StatusOOMKilled, StatusFailedToStart},
+	StatusReady:         {StatusUnhealthy, StatusStopping, StatusExited, StatusKilled, StatusOOMKilled},
+	StatusUnhealthy:     {StatusReady, StatusStopping, StatusExited, StatusKilled, StatusOOMKilled},
+	StatusStopping:      {StatusExited, StatusKilled, StatusOOMKilled},
+	StatusExited:        {StatusRestarting},
+	StatusKilled:        {StatusRestarting},
+	StatusOOMKilled:     {StatusRestarting},
+	StatusRestarting:    {StatusStarting},
+	StatusFailedToStart: {},
+}
+
+// TransitionError is returned for transitions the state machine doesn't allow
+type TransitionError struct {
+	From PsStatus
+	To   PsStatus
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == "" {
+		from = "initial state"
+	}
+	return fmt.Sprintf("invalid process state transition from %s to %s", from, e.To)
+}
+
+// Lifecycle is the state machine of the supervised process. Transitions are validated, and each
+// valid transition is emitted once, in order
+type Lifecycle struct {
+	mutex sync.Mutex
+	state PsStatus
+	last  Ps //copy of the last transition, as given to Transition
+	emit  func(ps *Ps)
+}
+
+// NewLifecycle returns a state machine in its initial state, emit is called for each transition
+func NewLifecycle(emit func(ps *Ps)) *Lifecycle {
+	return &Lifecycle{emit: emit}
+}
+
+// Transition moves to ps.Status and emits ps, or returns a *TransitionError (the same state
+// included unless allowed, so that concurrent observers of the process don't emit twice)
+func (l *Lifecycle) Transition(ps *Ps) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !CanTransition(l.state, ps.Status) {
+		return &TransitionError{From: l.state, To: ps.Status}
+	}
+	l.state = ps.Status
+	l.last = *ps
+	if l.emit != nil {
+		l.emit(ps)
+	}
+	return nil
+}
+
+// State returns the current state, empty until the process is starting
+func (l *Lifecycle) State() PsStatus {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.state
+}
+
+// Last returns the last transition (i.e. with the message explaining the current state), its
+// status is empty until the process is starting
+func (l *Lifecycle) Last() Ps {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.last
+}
+
+// CanTransition returns whether the state machine allows moving from a state to another
+func CanTransition(from, to PsStatus) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
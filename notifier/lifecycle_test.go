@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLifecycle(t *testing.T) {
+	t.Parallel()
+	var emitted []PsStatus
+	l := NewLifecycle(func(ps *Ps) {
+		emitted = append(emitted, ps.Status)
+	})
+
+	// starting, ready, then exit and restart after a crash
+	valid := []PsStatus{StatusStarting, StatusReady, StatusKilled, StatusRestarting, StatusStarting, StatusReady, StatusStopping, StatusExited}
+	for _, s := range valid {
+		if err := l.Transition(&Ps{Status: s}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(emitted, valid) {
+		t.Fatalf("expected %v to be emitted, got %v", valid, emitted)
+	}
+	if l.State() != StatusExited {
+		t.Fatalf("expected state %q, got %q", StatusExited, l.State())
+	}
+
+	for _, invalid := range []PsStatus{StatusExited, StatusReady, StatusStarting, StatusAlive} {
+		err := l.Transition(&Ps{Status: invalid})
+		if te, ok := err.(*TransitionError); !ok || te.From != StatusExited || te.To != invalid {
+			t.Fatalf("expected a transition error from %q to %q, got %v", StatusExited, invalid, err)
+		}
+	}
+	if len(emitted) != len(valid) {
+		t.Fatalf("expected invalid transitions not to be emitted, got %v", emitted[len(valid):])
+	}
+	if last := l.Last(); last.Status != StatusExited {
+		t.Fatalf("expected the last transition to be kept, got %#v", last)
+	}
+
+	if err := NewLifecycle(nil).Transition(&Ps{Status: StatusReady}); err == nil {
+		t.Fatal("expected the initial state to only lead to initializing or starting")
+	}
+
+	// one initializing transition per init step
+	l = NewLifecycle(nil)
+	for _, s := range []PsStatus{StatusInitializing, StatusInitializing, StatusStarting} {
+		if err := l.Transition(&Ps{Status: s}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Transition(&Ps{Status: StatusInitializing}); err == nil {
+		t.Fatal("expected init steps to run before the process starts only")
+	}
+}
+
+func TestLifecycleEmitsOnce(t *testing.T) {
+	t.Parallel()
+	var (
+		mutex sync.Mutex
+		ready int
+	)
+	l := NewLifecycle(func(ps *Ps) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if ps.Status == StatusReady {
+			ready++
+		}
+	})
+	if err := l.Transition(&Ps{Status: StatusStarting}); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent observers (i.e. port watcher and restarts) may report the same state
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Transition(&Ps{Status: StatusReady})
+		}()
+	}
+	wg.Wait()
+	if ready != 1 {
+		t.Fatalf("expected ready to be emitted once, got %d", ready)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	t.Parallel()
+	// every state is reachable, and transitions only lead to states
+	reachable := map[PsStatus]bool{}
+	for from, to := range transitions {
+		if from != "" && !validStatus(from) {
+			t.Fatalf("unknown state %q", from)
+		}
+		for _, s := range to {
+			if _, ok := transitions[s]; !ok {
+				t.Fatalf("%q leads to %q which isn't a state", from, s)
+			}
+			reachable[s] = true
+		}
+	}
+	for from := range transitions {
+		if from != "" && !reachable[from] {
+			t.Fatalf("state %q is unreachable", from)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package notifier
+
+import (
+	"net"
+
+	"github.com/robinmonjo/dock/procfs"
+)
+
+// AddressedNetInterfacesOnly omits interfaces without address from payloads
+var AddressedNetInterfacesOnly bool
+
+// NetInterface describes a network interface of the container
+type NetInterface struct {
+	Name      string   `json:"name"`
+	IPv4      string   `json:"ipv4,omitempty"`      //first IPv4 address
+	IPv6      string   `json:"ipv6,omitempty"`      //first IPv6 address
+	Addresses []string `json:"addresses,omitempty"` //all addresses, CIDR notation
+	MAC       string   `json:"mac,omitempty"`
+	MTU       int      `json:"mtu"`
+	Up        bool     `json:"up"`
+	Loopback  bool     `json:"loopback"`
+	Gateway   string   `json:"gateway,omitempty"` //IPv4 default gateway, if the default route goes through the interface
+}
+
+func netInterfaces() (netInterfaces []*NetInterface) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		hookLog.Error(err)
+		return
+	}
+
+	// no routing table isn't worth failing the notification
+	routes, err := procfs.ReadRoutes()
+	if err != nil {
+		hookLog.Debugf("routes: %v", err)
+	}
+
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			hookLog.Error(err)
+			continue
+		}
+
+		netInterface := newNetInterface(iface, addrs, routes)
+		if len(netInterface.Addresses) == 0 && AddressedNetInterfacesOnly {
+			continue
+		}
+		netInterfaces = append(netInterfaces, netInterface)
+	}
+	return
+}
+
+func newNetInterface(iface net.Interface, addrs []net.Addr, routes []*procfs.Route) *NetInterface {
+	netInterface := &NetInterface{
+		Name:     iface.Name,
+		MAC:      iface.HardwareAddr.String(),
+		MTU:      iface.MTU,
+		Up:       iface.Flags&net.FlagUp != 0,
+		Loopback: iface.Flags&net.FlagLoopback != 0,
+	}
+
+	for _, addr := range addrs {
+		var ip net.IP
+		switch v := addr.(type) {
+		case *net.IPNet:
+			ip = v.IP
+			netInterface.Addresses = append(netInterface.Addresses, v.String())
+		case *net.IPAddr:
+			ip = v.IP
+			netInterface.Addresses = append(netInterface.Addresses, v.String())
+		}
+
+		if ip.To4() != nil {
+			if netInterface.IPv4 == "" {
+				netInterface.IPv4 = ip.String()
+			}
+		} else if ip != nil && netInterface.IPv6 == "" {
+			netInterface.IPv6 = ip.String()
+		}
+	}
+
+	// lowest metric wins when several default routes go through the interface
+	var gateway *procfs.Route
+	for _, r := range routes {
+		if r.Iface == iface.Name && r.Default() && (gateway == nil || r.Metric < gateway.Metric) {
+			gateway = r
+		}
+	}
+	if gateway != nil {
+		netInterface.Gateway = gateway.Gateway.String()
+	}
+	return netInterface
+}
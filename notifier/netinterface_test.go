@@ -0,0 +1,69 @@
+package notifier
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/robinmonjo/dock/procfs"
+)
+
+func TestNewNetInterface(t *testing.T) {
+	t.Parallel()
+	mac, _ := net.ParseMAC("02:42:ac:11:00:02")
+	iface := net.Interface{Name: "eth0", MTU: 1500, HardwareAddr: mac, Flags: net.FlagUp | net.FlagBroadcast}
+
+	var addrs []net.Addr
+	for _, cidr := range []string{"172.17.0.2/16", "10.0.0.5/8", "fe80::42:acff:fe11:2/64", "2001:db8::2/64"} {
+		ip, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ipnet.IP = ip
+		addrs = append(addrs, ipnet)
+	}
+
+	routes, err := procfs.NewFS("../procfs/assets/proc").ReadRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &NetInterface{
+		Name:      "eth0",
+		IPv4:      "172.17.0.2",
+		IPv6:      "fe80::42:acff:fe11:2",
+		Addresses: []string{"172.17.0.2/16", "10.0.0.5/8", "fe80::42:acff:fe11:2/64", "2001:db8::2/64"},
+		MAC:       "02:42:ac:11:00:02",
+		MTU:       1500,
+		Up:        true,
+		Gateway:   "172.17.0.1",
+	}
+	if ni := newNetInterface(iface, addrs, routes); !reflect.DeepEqual(ni, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, ni)
+	}
+
+	// the default route through eth1 isn't up
+	down := net.Interface{Name: "eth1", MTU: 65536, Flags: net.FlagLoopback}
+	ni := newNetInterface(down, nil, routes)
+	if ni.Gateway != "" || ni.Up || !ni.Loopback || ni.MAC != "" || len(ni.Addresses) != 0 {
+		t.Fatalf("unexpected interface %#v", ni)
+	}
+}
+
+func TestNetInterfaces(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(netInterfaces()); n != len(ifaces) {
+		t.Fatalf("expected every interface to be reported (%d), got %d", len(ifaces), n)
+	}
+
+	defer func() { AddressedNetInterfacesOnly = false }()
+	AddressedNetInterfacesOnly = true
+	for _, ni := range netInterfaces() {
+		if len(ni.Addresses) == 0 {
+			t.Fatalf("expected %s to be omitted, it has no address", ni.Name)
+		}
+	}
+}
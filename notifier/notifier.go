@@ -3,118 +3,249 @@ package notifier
 import (
 	"bytes"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"net"
+	"io"
+	"io/ioutil"
 	"net/http"
-	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/robinmonjo/dock/cgroup"
+	"github.com/robinmonjo/dock/procfs"
 )
 
 type PsStatus string
 
+// process states, see Lifecycle for the valid transitions
 const (
-	StatusStarting PsStatus = "starting"
-	StatusRunning  PsStatus = "running"
-	StatusCrashed  PsStatus = "crashed"
+	StatusInitializing  PsStatus = "initializing" //an init step is running, see Message
+	StatusStarting      PsStatus = "starting"
+	StatusReady         PsStatus = "ready" //started, and the expected port is bound if any
+	StatusUnhealthy     PsStatus = "unhealthy"
+	StatusStopping      PsStatus = "stopping" //a stopping signal was forwarded
+	StatusExited        PsStatus = "exited"   //by itself, see Exit.Code
+	StatusKilled        PsStatus = "killed"   //by a signal, see Exit.Signal
+	StatusOOMKilled     PsStatus = "oom-killed"
+	StatusRestarting    PsStatus = "restarting"
+	StatusFailedToStart PsStatus = "failed-to-start"
 )
 
-var WebHook string
+// events, notified without changing the process state
+const (
+	StatusAlive PsStatus = "alive"
+)
+
+// Statuses lists every status a hook may subscribe to
+var Statuses = []PsStatus{
+	StatusInitializing, StatusStarting, StatusReady, StatusUnhealthy, StatusStopping, StatusExited, StatusKilled,
+	StatusOOMKilled, StatusRestarting, StatusFailedToStart, StatusAlive,
+}
+
+const (
+	// PayloadVersion is the version of the hook payload format, incremented on breaking changes
+	PayloadVersion = 2
+
+	sendTimeout = 10 * time.Second
+)
+
+var (
+	DockVersion string //reported in payloads
+
+	hookLog = log.WithField("component", "web-hook")
+
+	seq uint64 //sequence number of the last payload
+
+	hostOnce    sync.Once
+	hostname    string
+	containerID string
+)
 
 type Ps struct {
 	Status        PsStatus        `json:"status"`
+	Message       string          `json:"message,omitempty"`
+	Pid           int             `json:"pid,omitempty"` //0 until the process is started
+	Argv          []string        `json:"argv,omitempty"`
+	StartedAt     *time.Time      `json:"started_at,omitempty"`
+	Port          *Port           `json:"port,omitempty"`
+	Restarts      int             `json:"restarts"`
+	Uptime        float64         `json:"uptime,omitempty"` //seconds since the process started, alive only
+	Resources     *Resources      `json:"resources,omitempty"`
+	Exit          *Exit           `json:"exit,omitempty"`
 	NetInterfaces []*NetInterface `json:"net_interfaces"`
 }
 
-type NetInterface struct {
-	Name string `json:"name"`
-	IPv4 string `json:"ipv4,omitempty"`
-	IPv6 string `json:"ipv6,omitempty"`
+// Resources is a snapshot of the resources used by the process tree
+type Resources struct {
+	RSS         uint64  `json:"rss"`      //bytes
+	CPUTime     float64 `json:"cpu_time"` //seconds, including waited for children
+	Descendants int     `json:"descendants"`
+	OpenFds     int     `json:"open_fds"`
+}
+
+// Port describes the port the process is expected to bind (see --bind-port)
+type Port struct {
+	Port      string `json:"port"`
+	Bound     bool   `json:"bound"`
+	BinderPid int    `json:"binder_pid,omitempty"` //only known with strict port binding
+}
+
+// Exit describes how a process ended and the resources it used
+type Exit struct {
+	Pid        int      `json:"pid"`
+	Argv       []string `json:"argv,omitempty"`
+	Status     int      `json:"status"`           //exit status as a shell would report it (128 + n when killed by signal n)
+	Code       int      `json:"code"`             //exit code, 0 when killed by a signal
+	Signal     string   `json:"signal,omitempty"` //terminating signal (i.e. SIGKILL)
+	CoreDumped bool     `json:"core_dumped"`
+	UserTime   float64  `json:"user_time"`   //seconds
+	SystemTime float64  `json:"system_time"` //seconds
+	MaxRSS     int64    `json:"max_rss"`     //kilobytes
 }
 
 type HookPayload struct {
-	Ps *Ps `json:"ps"`
+	Version     int       `json:"version"`
+	Seq         uint64    `json:"seq"` //incremented for each payload, starts at 1
+	Timestamp   time.Time `json:"timestamp"`
+	Hostname    string    `json:"hostname"`
+	ContainerID string    `json:"container_id,omitempty"`
+	DockVersion string    `json:"dock_version"`
+	Ps          *Ps       `json:"ps"`
 }
 
-func NotifyHook(status PsStatus) error {
-	payload := &HookPayload{
-		&Ps{
-			Status:        status,
-			NetInterfaces: netInterfaces(),
-		},
+// NewPayload wraps the process information with the event and host context
+func NewPayload(ps *Ps) *HookPayload {
+	hostOnce.Do(func() {
+		var err error
+		if hostname, err = os.Hostname(); err != nil {
+			hookLog.Error(err)
+		}
+		containerID = cgroup.ContainerID(procfs.Self())
+	})
+
+	if ps.NetInterfaces == nil {
+		ps.NetInterfaces = netInterfaces()
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return err
+	return &HookPayload{
+		Version:     PayloadVersion,
+		Seq:         atomic.AddUint64(&seq, 1),
+		Timestamp:   time.Now().UTC(),
+		Hostname:    hostname,
+		ContainerID: containerID,
+		DockVersion: DockVersion,
+		Ps:          ps,
 	}
+}
 
-	req, err := http.NewRequest("PUT", WebHook, bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
+// StatusError is returned when the web hook responds with a non 2xx status code
+type StatusError struct {
+	Code int
+}
 
-	u, err := url.Parse(WebHook)
-	if err != nil {
-		return err
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status code expected 200 .. 299 got %d", e.Code)
+}
+
+// Temporary reports whether retrying may succeed. Client errors won't, except for
+// timeouts and rate limiting
+func (e *StatusError) Temporary() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout || e.Code == 429 //too many requests
+}
+
+// Hook is a web hook endpoint (http and https targets)
+type Hook struct {
+	URL       string
+	Method    string        //PUT if empty
+	Timeout   time.Duration //10 seconds if 0
+	Secret    []byte        //payloads are signed if not empty (see Sign)
+	Headers   http.Header   //extra headers (i.e. Authorization)
+	TLSConfig *tls.Config   //used for https URLs, servers are verified against system roots if nil
+	Format    string        //FormatJSON if empty, or one of the CloudEvents formats
+	Source    string        //CloudEvents source, see NewCloudEvent
+	Client    *http.Client  //reused across deliveries, see NewClient. Built on first send if nil
+}
+
+// NewClient returns the HTTP client of the hook, built from its timeout and TLS configuration.
+// Its connections are kept alive between deliveries
+func (h *Hook) NewClient() *http.Client {
+	client := &http.Client{Timeout: h.Timeout}
+	if client.Timeout == 0 {
+		client.Timeout = sendTimeout
 	}
+	if h.TLSConfig != nil {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: h.TLSConfig,
+		}
+	}
+	return client
+}
 
-	var client *http.Client
+// Send a JSON encoded payload to the hook, once
+func (h *Hook) Send(body []byte) error {
+	method := h.Method
+	if method == "" {
+		method = "PUT"
+	}
 
-	if u.Scheme == "https" {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	header := http.Header{}
+	for name, values := range h.Headers {
+		for _, v := range values {
+			header.Add(name, v)
+		}
+	}
+	contentType := "application/json"
+	if h.Format != "" && h.Format != FormatJSON {
+		event, err := NewCloudEvent(body, h.Source)
+		if err != nil {
+			return err
+		}
+		if body, contentType, err = event.encode(h.Format, header); err != nil {
+			return err
 		}
-		client = &http.Client{Transport: tr}
-	} else {
-		client = &http.Client{}
 	}
 
-	resp, err := client.Do(req)
+	req, err := http.NewRequest(method, h.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", contentType)
+	if len(h.Secret) > 0 {
+		// signed on each attempt, receivers may reject old signatures
+		req.Header.Set(SignatureHeader, Sign(h.Secret, body, time.Now()))
+	}
+
+	if h.Client == nil {
+		h.Client = h.NewClient()
+	}
+	resp, err := h.Client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body) //so that the connection is reused
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("bad status code expected 200 .. 299 got %d", resp.Status)
+		return &StatusError{resp.StatusCode}
 	}
 	return nil
 }
 
-func netInterfaces() (netInterfaces []*NetInterface) {
-	ifaces, err := net.Interfaces()
-	if err != nil {
-		log.Error(err)
-		return
-	}
-
-	for _, iface := range ifaces {
-		addrs, err := iface.Addrs()
-		if err != nil {
-			log.Error(err)
-			continue
-		}
-
-		netInterface := &NetInterface{
-			Name: iface.Name,
-		}
-
-		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
-			}
-
-			if ip.To4() != nil {
-				netInterface.IPv4 = ip.String()
-			} else {
-				netInterface.IPv6 = ip.String()
-			}
+// ParseHeaders parses headers in the "Name: value" form
+func ParseHeaders(headers []string) (http.Header, error) {
+	h := http.Header{}
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
 		}
-		netInterfaces = append(netInterfaces, netInterface)
+		h.Add(name, strings.TrimSpace(parts[1]))
 	}
-	return
+	return h, nil
 }
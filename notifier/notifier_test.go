@@ -0,0 +1,222 @@
+package notifier
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHookSend(t *testing.T) {
+	secret := []byte("s3cr3t")
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("expected authorization header, got %q", auth)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := VerifySignature(secret, body, r.Header.Get(SignatureHeader), time.Minute); err != nil {
+			t.Errorf("invalid signature %q: %v", r.Header.Get(SignatureHeader), err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	headers, err := ParseHeaders([]string{"Authorization: Bearer token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := &Hook{URL: server.URL, Secret: secret, Headers: headers}
+
+	if err := hook.Send([]byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	status = http.StatusUnauthorized
+	err = hook.Send([]byte("{}"))
+	if se, ok := err.(*StatusError); !ok || se.Code != status || se.Temporary() {
+		t.Fatalf("expected a permanent status error, got %v", err)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	t.Parallel()
+	h, err := ParseHeaders([]string{"X-Tenant:  acme ", "X-Tenant: other", "Authorization: Basic a2V5OnZhbHVl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenants := h["X-Tenant"]; len(tenants) != 2 || tenants[0] != "acme" {
+		t.Fatalf("unexpected X-Tenant values %v", tenants)
+	}
+	if auth := h.Get("Authorization"); auth != "Basic a2V5OnZhbHVl" {
+		t.Fatalf("unexpected Authorization value %q", auth)
+	}
+
+	for _, invalid := range []string{"Authorization", ": value"} {
+		if _, err := ParseHeaders([]string{invalid}); err == nil {
+			t.Fatalf("expected an error for header %q", invalid)
+		}
+	}
+}
+
+func TestHookReusesConnections(t *testing.T) {
+	var mutex sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			conns++
+			mutex.Unlock()
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	cert, err := x509.ParseCertificate(server.TLS.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	hook := &Hook{URL: server.URL, TLSConfig: &tls.Config{RootCAs: pool}}
+	hook.Client = hook.NewClient()
+
+	for i := 0; i < 3; i++ {
+		if err := hook.Send([]byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if conns != 1 {
+		t.Fatalf("expected deliveries to share a connection, got %d connections", conns)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	type request struct {
+		method string
+		seq    uint64
+		status PsStatus
+	}
+	newServer := func(c chan request) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var p HookPayload
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				t.Error(err)
+			}
+			c <- request{r.Method, p.Seq, p.Ps.Status}
+		}))
+	}
+
+	deploys, alerts := make(chan request, 10), make(chan request, 10)
+	deployServer, alertServer := newServer(deploys), newServer(alerts)
+	defer deployServer.Close()
+	defer alertServer.Close()
+
+	d := &Dispatcher{}
+	for _, spec := range []string{deployServer.URL + ";events=ready", alertServer.URL + ";events=exited,killed,oom-killed;method=POST"} {
+		target, err := ParseTarget(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Add(target, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, status := range []PsStatus{StatusStarting, StatusReady, StatusExited} {
+		d.Notify(&Ps{Status: status, NetInterfaces: []*NetInterface{}})
+	}
+	if err := d.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deploys) != 1 || len(alerts) != 1 {
+		t.Fatalf("expected 1 request per hook, got %d and %d", len(deploys), len(alerts))
+	}
+	deploy, alert := <-deploys, <-alerts
+	if deploy.method != "PUT" || deploy.status != StatusReady {
+		t.Fatalf("unexpected deploy hook request %#v", deploy)
+	}
+	if alert.method != "POST" || alert.status != StatusExited || alert.seq != deploy.seq+1 {
+		t.Fatalf("unexpected alert hook request %#v", alert)
+	}
+}
+
+func TestDispatcherOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var failures []string
+	d := &Dispatcher{OnFailure: func(t *Target, err error) {
+		failures = append(failures, t.URL+": "+err.Error())
+	}}
+	target, err := ParseTarget(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Add(target, ""); err != nil {
+		t.Fatal(err)
+	}
+	d.Notify(&Ps{Status: StatusReady, NetInterfaces: []*NetInterface{}})
+	if err := d.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("expected 1 failure, got %v", failures)
+	}
+	if delivered, failed := d.Stats(); delivered != 0 || failed != 1 {
+		t.Fatalf("expected 1 failed attempt, got %d delivered and %d failed", delivered, failed)
+	}
+}
+
+func TestDispatcherSpools(t *testing.T) {
+	spool, err := ioutil.TempDir("", "dock-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spool)
+
+	// same URL with other options, and the same target twice
+	url := "file://" + filepath.Join(spool, "events.jsonl")
+	d := &Dispatcher{}
+	for _, spec := range []string{url + ";events=ready", url + ";events=exited", url + ";events=exited"} {
+		target, err := ParseTarget(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Add(target, spool); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := ioutil.ReadDir(spool)
+	dirs := 0
+	for _, f := range files {
+		if f.IsDir() {
+			dirs++
+		}
+	}
+	if dirs != 3 {
+		t.Fatalf("expected a spool per target, got %d", dirs)
+	}
+}
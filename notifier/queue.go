@@ -0,0 +1,253 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	minBackoff = 500 * time.Millisecond //delay before the first retry, doubled on each failure
+	maxBackoff = 30 * time.Second
+	maxQueued  = 1000 //oldest payloads are dropped beyond this limit
+)
+
+// Queue delivers payloads asynchronously and in order: a payload is sent only once the previous
+// one has been delivered. Failed deliveries are retried with an exponential backoff.
+// If a spool directory is given, payloads are stored on disk until they are delivered so that
+// payloads still queued when dock exits are delivered by the next dock using the same spool
+type Queue struct {
+	delivered uint64 //first for 64 bits alignment of atomic operations
+	failed    uint64 //failed delivery attempts
+
+	send  func(body []byte) error
+	spool string
+
+	mutex  sync.Mutex
+	items  []*queueItem
+	closed bool
+
+	wake    chan bool
+	closing chan bool
+	done    chan bool
+}
+
+type queueItem struct {
+	body []byte
+	file string //spool file, "" if not spooled
+}
+
+// NewQueue starts delivering payloads with send. Payloads found in spool (if not empty) are
+// delivered first
+func NewQueue(send func(body []byte) error, spool string) (*Queue, error) {
+	q := &Queue{
+		send:    send,
+		spool:   spool,
+		wake:    make(chan bool, 1),
+		closing: make(chan bool),
+		done:    make(chan bool),
+	}
+
+	if spool != "" {
+		if err := os.MkdirAll(spool, 0700); err != nil {
+			return nil, err
+		}
+		items, err := readSpool(spool)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			hookLog.Debugf("%d payloads found in spool %s", len(items), spool)
+		}
+		q.items = items
+	}
+
+	go q.run()
+	return q, nil
+}
+
+// Push queues the payload for delivery
+func (q *Queue) Push(payload *HookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		hookLog.Error(err)
+		return
+	}
+
+	// closed is checked before spooling, or the payload would be delivered by the next dock
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		hookLog.Errorf("queue closed, payload %d not sent", payload.Seq)
+		return
+	}
+
+	item := &queueItem{body: body}
+	if q.spool != "" {
+		// file names sort in emission order, even across dock restarts
+		name := fmt.Sprintf("%020d-%010d.json", payload.Timestamp.UnixNano(), payload.Seq)
+		if err := writeSpoolFile(q.spool, name, body); err != nil {
+			hookLog.Errorf("failed to spool payload: %v", err)
+		} else {
+			item.file = filepath.Join(q.spool, name)
+		}
+	}
+
+	if len(q.items) >= maxQueued {
+		hookLog.Errorf("more than %d payloads queued, dropping the oldest one", maxQueued)
+		q.remove(q.items[0])
+	}
+	q.items = append(q.items, item)
+	q.mutex.Unlock()
+
+	select {
+	case q.wake <- true:
+	default:
+	}
+}
+
+// Close stops accepting payloads and waits at most timeout for the queued ones to be delivered
+func (q *Queue) Close(timeout time.Duration) error {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+	}
+	q.mutex.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	n := q.Len()
+	if q.spool != "" {
+		return fmt.Errorf("web hook: %d payloads not delivered, kept in spool %s", n, q.spool)
+	}
+	return fmt.Errorf("web hook: %d payloads not delivered", n)
+}
+
+// Len returns the number of payloads waiting for delivery
+func (q *Queue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.items)
+}
+
+// Stats returns the number of payloads delivered and of failed delivery attempts
+func (q *Queue) Stats() (delivered, failed uint64) {
+	return atomic.LoadUint64(&q.delivered), atomic.LoadUint64(&q.failed)
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	backoff := minBackoff
+	for {
+		item := q.head()
+		if item == nil {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.closing:
+				if q.Len() == 0 {
+					return
+				}
+				continue
+			}
+		}
+
+		err := q.send(item.body)
+		if err == nil {
+			atomic.AddUint64(&q.delivered, 1)
+			q.pop(item)
+			backoff = minBackoff
+			continue
+		}
+		atomic.AddUint64(&q.failed, 1)
+
+		if se, ok := err.(*StatusError); ok && !se.Temporary() {
+			hookLog.Errorf("%v, dropping payload", err)
+			q.pop(item)
+			backoff = minBackoff
+			continue
+		}
+
+		hookLog.Errorf("%v, retrying in %v", err, backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (q *Queue) head() *queueItem {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	return q.items[0]
+}
+
+// remove the item once delivered (or dropped), unless it was dropped meanwhile
+func (q *Queue) pop(item *queueItem) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.items) > 0 && q.items[0] == item {
+		q.remove(item)
+	}
+}
+
+// must be called with the mutex locked, item must be the head of the queue
+func (q *Queue) remove(item *queueItem) {
+	q.items = q.items[1:]
+	if item.file != "" {
+		if err := os.Remove(item.file); err != nil && !os.IsNotExist(err) {
+			hookLog.Error(err)
+		}
+	}
+}
+
+// write then rename, so that a spool never contains partial payloads
+func writeSpoolFile(dir, name string, body []byte) error {
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+func readSpool(dir string) ([]*queueItem, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	items := []*queueItem{}
+	for _, f := range files {
+		body, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &queueItem{body: body, file: f})
+	}
+	return items, nil
+}
@@ -0,0 +1,160 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	minBackoff = time.Millisecond
+	maxBackoff = 5 * time.Millisecond
+}
+
+// recorder records the sequence numbers of delivered payloads, failing the first
+// attempts with the given errors
+type recorder struct {
+	mutex     sync.Mutex
+	errors    []error
+	delivered []uint64
+	attempts  int
+}
+
+func (r *recorder) send(body []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.attempts++
+	if len(r.errors) > 0 {
+		err := r.errors[0]
+		r.errors = r.errors[1:]
+		return err
+	}
+	var p HookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return err
+	}
+	r.delivered = append(r.delivered, p.Seq)
+	return nil
+}
+
+func payload(seq uint64) *HookPayload {
+	return &HookPayload{Version: PayloadVersion, Seq: seq, Timestamp: time.Now(), Ps: &Ps{Status: StatusReady}}
+}
+
+func checkDelivered(t *testing.T, r *recorder, expected ...uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if fmt.Sprint(r.delivered) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v to be delivered, got %v", expected, r.delivered)
+	}
+}
+
+func TestQueueRetriesInOrder(t *testing.T) {
+	r := &recorder{errors: []error{fmt.Errorf("connection refused"), &StatusError{503}}}
+	q, err := NewQueue(r.send, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		q.Push(payload(i))
+	}
+	if err := q.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkDelivered(t, r, 1, 2, 3)
+	if r.attempts != 5 {
+		t.Fatalf("expected 5 attempts, got %d", r.attempts)
+	}
+	if delivered, failed := q.Stats(); delivered != 3 || failed != 2 {
+		t.Fatalf("expected 3 payloads delivered and 2 failed attempts, got %d and %d", delivered, failed)
+	}
+}
+
+func TestQueueDropsRejectedPayloads(t *testing.T) {
+	r := &recorder{errors: []error{&StatusError{400}}}
+	q, err := NewQueue(r.send, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Push(payload(1))
+	q.Push(payload(2))
+	if err := q.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkDelivered(t, r, 2)
+}
+
+func TestQueueFlushDeadline(t *testing.T) {
+	q, err := NewQueue(func([]byte) error { return fmt.Errorf("unreachable") }, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Push(payload(1))
+
+	start := time.Now()
+	if err := q.Close(50 * time.Millisecond); err == nil {
+		t.Fatal("expected an error when payloads can't be delivered")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("flush took %v, longer than its deadline", d)
+	}
+	q.Push(payload(2)) //must not be queued once closed
+	if q.Len() != 1 {
+		t.Fatalf("expected 1 payload left, got %d", q.Len())
+	}
+}
+
+func TestQueueSpool(t *testing.T) {
+	spool, err := ioutil.TempDir("", "dock-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spool)
+
+	// dock can't reach the hook before exiting
+	q, err := NewQueue(func([]byte) error { return fmt.Errorf("unreachable") }, spool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Push(payload(1))
+	q.Push(payload(2))
+	if err := q.Close(20 * time.Millisecond); err == nil {
+		t.Fatal("expected an error when payloads can't be delivered")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(spool, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 spooled payloads, got %d", len(files))
+	}
+
+	// next dock delivers spooled payloads before its own
+	r := &recorder{}
+	q, err = NewQueue(r.send, spool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := payload(1)
+	p.Timestamp = time.Now()
+	q.Push(p)
+	if err := q.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkDelivered(t, r, 1, 2, 1)
+
+	files, _ = filepath.Glob(filepath.Join(spool, "*"))
+	if len(files) != 0 {
+		t.Fatalf("expected an empty spool, got %v", files)
+	}
+
+	// payloads pushed once closed are neither sent nor spooled for the next dock
+	q.Push(payload(3))
+	files, _ = filepath.Glob(filepath.Join(spool, "*"))
+	if len(files) != 0 {
+		t.Fatalf("expected an empty spool after a push on a closed queue, got %v", files)
+	}
+}
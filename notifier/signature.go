@@ -0,0 +1,77 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header carrying the signature of signed payloads, in the form
+// "t=<unix timestamp>,v1=<hex encoded HMAC-SHA256 of "<timestamp>.<body>">"
+const SignatureHeader = "X-Dock-Signature"
+
+var (
+	ErrNoSignature      = errors.New("no valid signature found")
+	ErrSignatureExpired = errors.New("signature timestamp out of tolerance")
+)
+
+// Sign returns the signature header value of body sent at t. The timestamp is part of the
+// signed content so that receivers can reject replayed payloads
+func Sign(secret, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac(secret, ts, body)))
+}
+
+// VerifySignature checks that the signature header value matches body. Signatures made more than
+// tolerance away from now are rejected, a zero tolerance disables the check
+func VerifySignature(secret, body []byte, header string, tolerance time.Duration) error {
+	var ts string
+	var signatures [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			if sig, err := hex.DecodeString(kv[1]); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrNoSignature
+	}
+
+	if tolerance > 0 {
+		if d := time.Since(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+			return ErrSignatureExpired
+		}
+	}
+
+	expected := mac(secret, ts, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return ErrNoSignature
+}
+
+func mac(secret []byte, ts string, body []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(body)
+	return m.Sum(nil)
+}
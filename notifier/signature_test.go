@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	t.Parallel()
+	secret := []byte("s3cr3t")
+	body := []byte(`{"version":1,"seq":1}`)
+
+	header := Sign(secret, body, time.Now())
+	if err := VerifySignature(secret, body, header, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifySignature([]byte("other"), body, header, time.Minute); err != ErrNoSignature {
+		t.Fatalf("expected %v with a wrong secret, got %v", ErrNoSignature, err)
+	}
+	if err := VerifySignature(secret, []byte(`{"version":1,"seq":2}`), header, time.Minute); err != ErrNoSignature {
+		t.Fatalf("expected %v with a tampered body, got %v", ErrNoSignature, err)
+	}
+
+	old := Sign(secret, body, time.Now().Add(-time.Hour))
+	if err := VerifySignature(secret, body, old, time.Minute); err != ErrSignatureExpired {
+		t.Fatalf("expected %v with an old signature, got %v", ErrSignatureExpired, err)
+	}
+	if err := VerifySignature(secret, body, old, 0); err != nil {
+		t.Fatalf("expected no timestamp check with a 0 tolerance, got %v", err)
+	}
+
+	for _, invalid := range []string{"", "v1=abc", "t=123", "t=abc,v1=00"} {
+		if err := VerifySignature(secret, body, invalid, 0); err != ErrNoSignature {
+			t.Fatalf("expected %v for header %q, got %v", ErrNoSignature, invalid, err)
+		}
+	}
+}
+
+func TestSignFormat(t *testing.T) {
+	t.Parallel()
+	// echo -n '1455186727.{}' | openssl dgst -sha256 -hmac key
+	expected := "t=1455186727,v1=459a42ddd38848c76eb9b329368532e36f76cf2b6006f6b4d22bb72d79239bd5"
+	if s := Sign([]byte("key"), []byte("{}"), time.Unix(1455186727, 0)); s != expected {
+		t.Fatalf("expected signature %q, got %q", expected, s)
+	}
+}
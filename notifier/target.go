@@ -0,0 +1,271 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Notifier delivers JSON encoded payloads to an endpoint
+type Notifier interface {
+	Send(body []byte) error
+}
+
+// Target is a notification endpoint and the statuses it subscribed to
+type Target struct {
+	URL      string     //as specified, identifies the target in logs
+	Spec     string     //URL and options, identifies the target spool
+	Events   []PsStatus //all statuses if empty
+	Notifier Notifier
+}
+
+// events of version 1 payloads, mapped to the lifecycle states replacing them
+var legacyEvents = map[PsStatus][]PsStatus{
+	"running": {StatusReady},
+	"crashed": {StatusExited, StatusKilled, StatusOOMKilled},
+	"warning": {StatusUnhealthy},
+}
+
+// ParseTarget parses a target specification: an URL optionally followed by semicolon separated
+// options, i.e. "https://example.com/hook;events=ready,exited;method=POST;timeout=5s".
+// The backend is selected by the URL scheme:
+//
+//   - http, https: web hook (options: method, header, timeout, format, source)
+//   - exec: run a command, i.e. exec:///usr/local/bin/on-event arg (option: timeout)
+//   - file: append payloads as JSON lines to a file or a FIFO
+//   - unix: write payloads as datagrams to a unix socket
+//   - statsd: increment <prefix>.process.<status> counters over UDP (options: prefix, tags)
+//
+// The events option applies to every backend, the header option may be repeated
+func ParseTarget(spec string) (*Target, error) {
+	parts := strings.Split(spec, ";")
+	t := &Target{URL: parts[0], Spec: spec}
+
+	options := map[string][]string{}
+	for _, option := range parts[1:] {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid notification option %q, expected key=value", option)
+		}
+		key := strings.TrimSpace(kv[0])
+		options[key] = append(options[key], strings.TrimSpace(kv[1]))
+	}
+
+	if events, ok := options["events"]; ok {
+		for _, e := range strings.Split(last(events), ",") {
+			status := PsStatus(strings.TrimSpace(e))
+			if statuses, ok := legacyEvents[status]; ok {
+				hookLog.Warnf("%s: event %q is deprecated, subscribing to %v instead", t.URL, status, statuses)
+				t.Events = append(t.Events, statuses...)
+				continue
+			}
+			if !validStatus(status) {
+				return nil, fmt.Errorf("unknown notification event %q, expected one of %v", status, Statuses)
+			}
+			t.Events = append(t.Events, status)
+		}
+		delete(options, "events")
+	}
+
+	var err error
+	switch scheme := strings.SplitN(t.URL, "://", 2)[0]; scheme {
+	case "http", "https":
+		t.Notifier, err = newHook(t.URL, options)
+	case "exec":
+		t.Notifier, err = newExec(t.URL, options)
+	case "file":
+		t.Notifier, err = newFile(t.URL, options)
+	case "unix":
+		t.Notifier, err = newUnix(t.URL, options)
+	case "statsd":
+		t.Notifier, err = newStatsd(t.URL, options)
+	default:
+		err = fmt.Errorf("unsupported notification URL %q, expected http, https, exec, file, unix or statsd", t.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Accepts returns whether the target subscribed to the status
+func (t *Target) Accepts(status PsStatus) bool {
+	if len(t.Events) == 0 {
+		return true
+	}
+	for _, e := range t.Events {
+		if e == status {
+			return true
+		}
+	}
+	return false
+}
+
+func newHook(uri string, options map[string][]string) (*Hook, error) {
+	if err := checkOptions(options, "method", "header", "timeout", "format", "source"); err != nil {
+		return nil, err
+	}
+	if _, err := url.Parse(uri); err != nil {
+		return nil, err
+	}
+	h := &Hook{URL: uri, Headers: http.Header{}}
+
+	if method, ok := options["method"]; ok {
+		m := last(method)
+		if m == "" || strings.ContainsAny(m, " \t") {
+			return nil, fmt.Errorf("invalid web hook method %q", m)
+		}
+		h.Method = strings.ToUpper(m)
+	}
+
+	headers, err := ParseHeaders(options["header"])
+	if err != nil {
+		return nil, err
+	}
+	h.Headers = headers
+
+	if format, ok := options["format"]; ok {
+		h.Format = last(format)
+		if !validFormat(h.Format) {
+			return nil, fmt.Errorf("unknown web hook format %q, expected one of %v", h.Format, Formats)
+		}
+	}
+	if source, ok := options["source"]; ok {
+		if h.Format != FormatCloudEvents && h.Format != FormatCloudEventsBinary {
+			return nil, fmt.Errorf("the source option requires a cloudevents format")
+		}
+		h.Source = last(source)
+	}
+
+	if h.Timeout, err = parseTimeout(options); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func newExec(uri string, options map[string][]string) (*Exec, error) {
+	if err := checkOptions(options, "timeout"); err != nil {
+		return nil, err
+	}
+	argv := strings.Fields(strings.TrimPrefix(uri, "exec://"))
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("no command in %q", uri)
+	}
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return nil, err
+	}
+	argv[0] = path
+
+	e := &Exec{Argv: argv}
+	if e.Timeout, err = parseTimeout(options); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func newFile(uri string, options map[string][]string) (*File, error) {
+	if err := checkOptions(options); err != nil {
+		return nil, err
+	}
+	path, err := urlPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: path}, nil
+}
+
+func newUnix(uri string, options map[string][]string) (*Unix, error) {
+	if err := checkOptions(options); err != nil {
+		return nil, err
+	}
+	path, err := urlPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &Unix{Path: path}, nil
+}
+
+func newStatsd(uri string, options map[string][]string) (*Statsd, error) {
+	if err := checkOptions(options, "prefix", "tags"); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no statsd address in %q, expected statsd://<host>:<port>", uri)
+	}
+
+	s := &Statsd{Addr: u.Host, Prefix: defaultStatsdPrefix}
+	if prefix, ok := options["prefix"]; ok {
+		s.Prefix = last(prefix)
+	}
+	if tags, ok := options["tags"]; ok {
+		s.Tags = strings.Split(last(tags), ",")
+	}
+	return s, nil
+}
+
+// file and unix URLs: file:///var/log/events.jsonl or file://events.jsonl (relative)
+func urlPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	path := u.Host + u.Path
+	if path == "" {
+		return "", fmt.Errorf("no path in %q", uri)
+	}
+	return path, nil
+}
+
+func parseTimeout(options map[string][]string) (time.Duration, error) {
+	timeout, ok := options["timeout"]
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(last(timeout))
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("notification timeout must be positive, got %s", last(timeout))
+	}
+	return d, nil
+}
+
+// returns an error for options not supported by a backend
+func checkOptions(options map[string][]string, supported ...string) error {
+	for key := range options {
+		found := false
+		for _, s := range supported {
+			if key == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown notification option %q", key)
+		}
+	}
+	return nil
+}
+
+// options given several times (but header) take their last value
+func last(values []string) string {
+	return values[len(values)-1]
+}
+
+func validStatus(status PsStatus) bool {
+	for _, s := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
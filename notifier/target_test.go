@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	t.Parallel()
+	target, err := ParseTarget("https://example.com/hook?a=b;events=ready, exited;method=post;header=Authorization: Bearer x;header=X-Tenant: acme;timeout=3s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, ok := target.Notifier.(*Hook)
+	if !ok {
+		t.Fatalf("expected a web hook, got %#v", target.Notifier)
+	}
+	if h.URL != "https://example.com/hook?a=b" || h.Method != "POST" || h.Timeout != 3*time.Second {
+		t.Fatalf("unexpected hook %#v", h)
+	}
+	if h.Headers.Get("Authorization") != "Bearer x" || h.Headers.Get("X-Tenant") != "acme" {
+		t.Fatalf("unexpected headers %v", h.Headers)
+	}
+	if !target.Accepts(StatusReady) || !target.Accepts(StatusExited) || target.Accepts(StatusStarting) {
+		t.Fatalf("unexpected events %v", target.Events)
+	}
+
+	target, err = ParseTarget("http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range Statuses {
+		if !target.Accepts(status) {
+			t.Fatalf("expected a target without events option to accept %q", status)
+		}
+	}
+
+	// version 1 event names are mapped to the states replacing them
+	target, err = ParseTarget("http://example.com;events=running,crashed,warning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []PsStatus{StatusReady, StatusExited, StatusKilled, StatusOOMKilled, StatusUnhealthy}; !reflect.DeepEqual(target.Events, expected) {
+		t.Fatalf("expected events %v, got %v", expected, target.Events)
+	}
+
+	target, err = ParseTarget("https://example.com/events;format=cloudevents-binary;source=urn:dock:web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := target.Notifier.(*Hook); h.Format != FormatCloudEventsBinary || h.Source != "urn:dock:web" {
+		t.Fatalf("unexpected hook format %q and source %q", h.Format, h.Source)
+	}
+
+	backends := []struct {
+		spec     string
+		expected Notifier
+	}{
+		{"exec://true --flag arg;timeout=1s", &Exec{Argv: []string{lookPath(t, "true"), "--flag", "arg"}, Timeout: time.Second}},
+		{"file:///var/log/events.jsonl", &File{Path: "/var/log/events.jsonl"}},
+		{"file://events.jsonl;events=killed", &File{Path: "events.jsonl"}},
+		{"unix:///run/events.sock", &Unix{Path: "/run/events.sock"}},
+		{"statsd://127.0.0.1:8125", &Statsd{Addr: "127.0.0.1:8125", Prefix: "dock"}},
+		{"statsd://127.0.0.1:8125;prefix=;tags=env:prod,team:infra", &Statsd{Addr: "127.0.0.1:8125", Tags: []string{"env:prod", "team:infra"}}},
+	}
+	for _, b := range backends {
+		target, err := ParseTarget(b.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", b.spec, err)
+		}
+		if !reflect.DeepEqual(target.Notifier, b.expected) {
+			t.Fatalf("%s: expected %#v, got %#v", b.spec, b.expected, target.Notifier)
+		}
+	}
+
+	for _, invalid := range []string{
+		"example.com",
+		"ftp://example.com",
+		"http://example.com;events=runing",
+		"http://example.com;timeout=abc",
+		"http://example.com;timeout=-1s",
+		"http://example.com;method=",
+		"http://example.com;header=Authorization",
+		"http://example.com;retries=3",
+		"http://example.com;events",
+		"http://example.com;format=xml",
+		"http://example.com;source=urn:dock:web",
+		"file:///var/log/events.jsonl;format=cloudevents",
+		"exec://",
+		"exec://dock-command-not-found",
+		"exec://true;method=POST",
+		"file://",
+		"unix://;timeout=1s",
+		"statsd://",
+		"statsd://127.0.0.1:8125;header=X-Tenant: acme",
+	} {
+		if _, err := ParseTarget(invalid); err == nil {
+			t.Fatalf("expected an error for %q", invalid)
+		}
+	}
+}
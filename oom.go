@@ -0,0 +1,48 @@
+package main
+
+import (
+	"syscall"
+
+	"github.com/robinmonjo/dock/cgroup"
+)
+
+// oomWatcher tells whether the process was killed by the kernel OOM killer, by comparing
+// the oom_kill counter of dock's cgroup before and after the process ran
+type oomWatcher struct {
+	cgroup *cgroup.Cgroup
+	kills  uint64
+}
+
+// returns nil if dock's cgroup memory events can't be read
+func newOOMWatcher() *oomWatcher {
+	cg, err := cgroup.Self()
+	if err != nil {
+		oomLog.Debugf("oom watcher disabled: %v", err)
+		return nil
+	}
+	events, err := cg.MemoryEvents()
+	if err != nil {
+		oomLog.Debugf("oom watcher disabled: %v", err)
+		return nil
+	}
+	return &oomWatcher{
+		cgroup: cg,
+		kills:  events.OOMKill,
+	}
+}
+
+// tell if the given exit status is the result of an OOM kill since the previous call, so that
+// restarted processes aren't reported as OOM killed because of a previous run
+func (w *oomWatcher) oomKilled(exit int) bool {
+	if w == nil {
+		return false
+	}
+	events, err := w.cgroup.MemoryEvents()
+	if err != nil {
+		oomLog.Error(err)
+		return false
+	}
+	kills := w.kills
+	w.kills = events.OOMKill
+	return exit == exitSignalOffset+int(syscall.SIGKILL) && events.OOMKill > kills
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/docker/docker/pkg/term"
 	"github.com/kr/pty"
 	"github.com/robinmonjo/dock/iowire"
+	"github.com/robinmonjo/dock/notifier"
+)
+
+var (
+	errNotRunning = errors.New("process is not running")
+	errStopping   = errors.New("process is being stopped")
 )
 
 type process struct {
@@ -18,6 +27,16 @@ type process struct {
 	wire      *iowire.Wire
 	pty       *os.File
 	termState *termState
+	bindPort  string //port the process is expected to bind, if any
+
+	mutex     sync.Mutex
+	restart   bool //restart requested
+	stop      bool //graceful stop requested, the process won't be restarted
+	running   bool //started and not exited yet
+	startedAt time.Time
+	startPid  int //pid of the last started process
+	restarts  int
+	port      *notifier.Port //binding state of bindPort
 }
 
 type termState struct {
@@ -43,20 +62,33 @@ func (p *process) start() error {
 
 	p.cmd = exec.Command(path, args...)
 
+	p.mutex.Lock()
+	p.restart = false
+	p.startPid = 0
+	p.port = nil
+	if p.bindPort != "" {
+		p.port = &notifier.Port{Port: p.bindPort}
+	}
+	p.mutex.Unlock()
+
 	p.cmd.SysProcAttr = &syscall.SysProcAttr{
 		Pdeathsig: syscall.SIGTERM,
 	}
 
 	if p.wire.Interactive() {
-		go func() {
-			<-p.wire.CloseCh
-			//if interactive and stream closed, send a sigterm to the process
-			p.signal(syscall.SIGTERM)
-		}()
-		return p.startInteractive()
+		err = p.startInteractive()
 	} else {
-		return p.startNonInteractive()
+		err = p.startNonInteractive()
 	}
+
+	if p.cmd.Process != nil {
+		p.mutex.Lock()
+		p.startedAt = time.Now().UTC()
+		p.startPid = p.cmd.Process.Pid
+		p.running = err == nil
+		p.mutex.Unlock()
+	}
+	return err
 }
 
 func (p *process) startNonInteractive() error {
@@ -108,18 +140,131 @@ func (p *process) startInteractive() error {
 	return nil
 }
 
+// if interactive and stream closed, send a sigterm to the process. Runs once for all the restarts
+func (p *process) watchWire() {
+	<-p.wire.CloseCh
+	p.signalRunning(0, syscall.SIGTERM)
+}
+
 func (p *process) wait() error {
 	return p.cmd.Wait()
 }
 
 func (p *process) cleanup() {
-	p.wire.Close()
 	if p.pty != nil {
 		p.pty.Close()
+		p.pty = nil
 	}
 	if p.termState != nil {
 		term.RestoreTerminal(p.termState.fd, p.termState.state)
+		p.termState = nil
+	}
+}
+
+// restart the running process: it's terminated and started again once it exits. As for
+// signalRunning, if pid isn't 0 the process must have this pid
+func (p *process) restartRunning(pid int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.running || (pid != 0 && pid != p.startPid) {
+		return errNotRunning
+	}
+	if p.stop {
+		return errStopping
+	}
+	p.restart = true
+	return p.cmd.Process.Signal(syscall.SIGTERM)
+}
+
+// terminate the running process, it won't be restarted
+func (p *process) stopRunning() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.running {
+		return errNotRunning
+	}
+	p.stop = true
+	p.restart = false
+	return p.cmd.Process.Signal(syscall.SIGTERM)
+}
+
+// signal the process if it's running. If pid isn't 0, the process must have this pid, so that
+// delayed signals don't reach a restarted process
+func (p *process) signalRunning(pid int, sig os.Signal) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.running || (pid != 0 && pid != p.startPid) {
+		return errNotRunning
+	}
+	return p.cmd.Process.Signal(sig)
+}
+
+// record that the process exited, it can't be signaled through signalRunning anymore
+func (p *process) exited() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.running = false
+}
+
+// pid of the process, 0 if it isn't running
+func (p *process) runningPid() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.running {
+		return 0
+	}
+	return p.startPid
+}
+
+func (p *process) restartRequested() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.restart
+}
+
+// count a restart, before the process is started again
+func (p *process) restarting() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.restarts++
+}
+
+// record the pid that bound the expected port (0 if unknown)
+func (p *process) portBound(binderPid int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.port != nil {
+		p.port.Bound = true
+		p.port.BinderPid = binderPid
+	}
+}
+
+// fill the process information notified with state changes
+func (p *process) describe(ps *notifier.Ps) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ps.Pid = p.startPid
+	ps.Argv = p.argv
+	ps.Restarts = p.restarts
+	if p.startPid != 0 {
+		startedAt := p.startedAt
+		ps.StartedAt = &startedAt
+	}
+	if p.port != nil {
+		port := *p.port
+		ps.Port = &port
+	}
+}
+
+// time since the process started, 0 if it isn't running
+func (p *process) uptime() time.Duration {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.running {
+		return 0
 	}
+	return time.Since(p.startedAt)
 }
 
 func (p *process) pid() int {
@@ -0,0 +1,137 @@
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProcCgroup is a control group membership of a process, as found in /proc/$PID/cgroup
+type ProcCgroup struct {
+	// hierarchy ID, 0 for the unified (cgroup v2) hierarchy
+	HierarchyID int
+	// controllers bound to the hierarchy, empty for the unified hierarchy
+	Controllers []string
+	// path of the cgroup relative to the hierarchy mount root
+	Path string
+}
+
+// Mount is a mount point of the process mount namespace, as found in /proc/$PID/mountinfo
+type Mount struct {
+	MountID      int
+	ParentID     int
+	Root         string // root of the mount within the file system (i.e. the cgroup path of a container)
+	MountPoint   string
+	Options      []string
+	FSType       string
+	Source       string
+	SuperOptions []string
+}
+
+// return the control groups the process belongs to
+func (p *Proc) Cgroups() ([]*ProcCgroup, error) {
+	f, err := os.Open(filepath.Join(p.dir(), "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cgroups := []*ProcCgroup{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		//format: hierarchy-ID:controller-list:cgroup-path
+		records := strings.SplitN(scanner.Text(), ":", 3)
+		if len(records) != 3 {
+			return nil, fmt.Errorf("unexpected cgroup line: %q", scanner.Text())
+		}
+		id, err := strconv.Atoi(records[0])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cgroup line: %q", scanner.Text())
+		}
+		cg := &ProcCgroup{
+			HierarchyID: id,
+			Path:        records[2],
+		}
+		if records[1] != "" {
+			cg.Controllers = strings.Split(records[1], ",")
+		}
+		cgroups = append(cgroups, cg)
+	}
+	return cgroups, scanner.Err()
+}
+
+// return the mount points visible by the process
+func (p *Proc) MountInfo() ([]*Mount, error) {
+	f, err := os.Open(filepath.Join(p.dir(), "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts := []*Mount{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m, err := parseMountInfoLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// format (see man 5 proc):
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+// optional fields (master:1) are variable in number and ended by a single hyphen
+func parseMountInfoLine(line string) (*Mount, error) {
+	fields := strings.Fields(line)
+
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return nil, fmt.Errorf("unexpected mountinfo line: %q", line)
+	}
+
+	m := &Mount{
+		Root:       unescapeMountPath(fields[3]),
+		MountPoint: unescapeMountPath(fields[4]),
+		Options:    strings.Split(fields[5], ","),
+		FSType:     fields[sep+1],
+		Source:     fields[sep+2],
+	}
+	m.MountID, _ = strconv.Atoi(fields[0])
+	m.ParentID, _ = strconv.Atoi(fields[1])
+	if len(fields) > sep+3 {
+		m.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	return m, nil
+}
+
+// paths in mountinfo have spaces, tabs, new lines and backslashes escaped as octal (\040)
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b []byte
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b = append(b, byte(c))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, path[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,59 @@
+package procfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCgroups(t *testing.T) {
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(9)
+	cgroups, err := p.Cgroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cgroups) != 5 {
+		t.Fatalf("expected 5 cgroups, got %d", len(cgroups))
+	}
+	expected := &ProcCgroup{HierarchyID: 10, Controllers: []string{"cpu", "cpuacct"}, Path: "/docker/4a7b5c0e7e3f"}
+	if !reflect.DeepEqual(cgroups[1], expected) {
+		t.Fatalf("expected cgroup %#v, got %#v", expected, cgroups[1])
+	}
+	unified := cgroups[4]
+	if unified.HierarchyID != 0 || unified.Controllers != nil || unified.Path != "/system.slice/containerd.service" {
+		t.Fatalf("unexpected unified cgroup %#v", unified)
+	}
+}
+
+func TestMountInfo(t *testing.T) {
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(9)
+	mounts, err := p.MountInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 8 {
+		t.Fatalf("expected 8 mounts, got %d", len(mounts))
+	}
+
+	//multiple optional fields
+	m := mounts[5]
+	expected := &Mount{
+		MountID:      246,
+		ParentID:     243,
+		Root:         "/docker/4a7b5c0e7e3f",
+		MountPoint:   "/sys/fs/cgroup/cpu,cpuacct",
+		Options:      []string{"ro", "nosuid", "nodev", "noexec", "relatime"},
+		FSType:       "cgroup",
+		Source:       "cgroup",
+		SuperOptions: []string{"rw", "cpu", "cpuacct"},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected mount %#v, got %#v", expected, m)
+	}
+
+	//escaped path
+	if root := mounts[7].Root; root != "/var/lib/docker/volumes/my data" {
+		t.Fatalf("expected root to be unescaped, got %q", root)
+	}
+}
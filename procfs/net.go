@@ -2,7 +2,9 @@ package procfs
 
 import (
 	"bufio"
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
@@ -20,6 +22,12 @@ const (
 
 var protocols = []string{"tcp", "tcp6", "udp", "udp6"}
 
+// route flags, see linux/route.h
+const (
+	RouteUp      = 0x1
+	RouteGateway = 0x2
+)
+
 type Socket struct {
 	Protocol   string
 	LocalIP    net.IP
@@ -29,7 +37,8 @@ type Socket struct {
 	Inode      string
 }
 
-func ReadNet() ([]*Socket, error) {
+// ReadNet returns the TCP and UDP sockets found in the net directory of the file system
+func (fs FS) ReadNet() ([]*Socket, error) {
 	var (
 		sockets = []*Socket{}
 		err     error
@@ -40,7 +49,7 @@ func ReadNet() ([]*Socket, error) {
 	wg.Add(len(protocols))
 	for _, proto := range protocols {
 		go func(p string) {
-			s, e := parseNetFile(p)
+			s, e := parseNetFile(fs.Path("net", p))
 			mutex.Lock()
 			if e != nil {
 				err = e
@@ -56,8 +65,14 @@ func ReadNet() ([]*Socket, error) {
 	return sockets, err
 }
 
-func parseNetFile(protocol string) ([]*Socket, error) {
-	f, err := os.Open(filepath.Join(Mountpoint, "net", protocol))
+// ReadNet returns the TCP and UDP sockets of the system
+func ReadNet() ([]*Socket, error) {
+	return defaultFS().ReadNet()
+}
+
+func parseNetFile(path string) ([]*Socket, error) {
+	protocol := filepath.Base(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +116,73 @@ func processLine(line, protocol string) *Socket {
 	return s
 }
 
+// Route is an IPv4 route, as found in /proc/net/route
+type Route struct {
+	Iface       string
+	Destination net.IP
+	Gateway     net.IP
+	Mask        net.IPMask
+	Flags       uint64
+	Metric      int
+	MTU         int
+}
+
+// Default returns whether the route is an usable default route (0.0.0.0/0 through a gateway)
+func (r *Route) Default() bool {
+	ones, _ := r.Mask.Size()
+	return r.Destination.Equal(net.IPv4zero) && ones == 0 && r.Flags&(RouteUp|RouteGateway) == RouteUp|RouteGateway
+}
+
+// ReadRoutes returns the IPv4 routing table found in the net directory of the file system
+func (fs FS) ReadRoutes() ([]*Route, error) {
+	f, err := os.Open(fs.Path("net", "route"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	routes := []*Route{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() //flush file header
+
+	for scanner.Scan() {
+		//format: Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		columns := strings.Fields(scanner.Text())
+		if len(columns) < 9 {
+			continue
+		}
+		r := &Route{Iface: columns[0]}
+		for i, ip := range []*net.IP{&r.Destination, &r.Gateway} {
+			if *ip, err = hexStringToIPv4(columns[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		mask, err := hexStringToIPv4(columns[7])
+		if err != nil {
+			return nil, err
+		}
+		r.Mask = net.IPMask(mask)
+		if r.Flags, err = strconv.ParseUint(columns[3], 16, 64); err != nil {
+			return nil, fmt.Errorf("unexpected route flags %q", columns[3])
+		}
+		if r.Metric, err = strconv.Atoi(columns[6]); err != nil {
+			return nil, fmt.Errorf("unexpected route metric %q", columns[6])
+		}
+		if r.MTU, err = strconv.Atoi(columns[8]); err != nil {
+			return nil, fmt.Errorf("unexpected route MTU %q", columns[8])
+		}
+		routes = append(routes, r)
+	}
+
+	return routes, scanner.Err()
+}
+
+// ReadRoutes returns the IPv4 routing table of the system
+func ReadRoutes() ([]*Route, error) {
+	return defaultFS().ReadRoutes()
+}
+
 //sort warppers
 type Sockets []*Socket
 
@@ -124,6 +206,17 @@ func hexStringToIP(str string) net.IP {
 	return net.IP(b)
 }
 
+// addresses in /proc/net/route are in host byte order, little endian on supported architectures
+func hexStringToIPv4(str string) (net.IP, error) {
+	n, err := strconv.ParseUint(str, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected route address %q", str)
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(n))
+	return net.IPv4(b[0], b[1], b[2], b[3]).To4(), nil
+}
+
 func hexStringToDecimalPort(str string) string {
 	p, _ := strconv.ParseInt(str, 16, 32)
 	return strconv.Itoa(int(p))
@@ -1,12 +1,13 @@
 package procfs
 
 import (
+	"net"
 	"testing"
 )
 
 func TestReadNet(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	sockets, err := ReadNet()
+	t.Parallel()
+	sockets, err := NewFS("./assets/proc").ReadNet()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -30,3 +31,30 @@ func TestReadNet(t *testing.T) {
 	}
 
 }
+
+func TestReadRoutes(t *testing.T) {
+	t.Parallel()
+	routes, err := NewFS("./assets/proc").ReadRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(routes))
+	}
+
+	gw := routes[0]
+	if gw.Iface != "eth0" || !gw.Gateway.Equal(net.ParseIP("172.17.0.1")) || !gw.Default() {
+		t.Fatalf("expected a default route through 172.17.0.1 on eth0, got %#v", gw)
+	}
+
+	local := routes[1]
+	ones, _ := local.Mask.Size()
+	if !local.Destination.Equal(net.ParseIP("172.17.0.0")) || ones != 16 || local.Default() {
+		t.Fatalf("expected a local route to 172.17.0.0/16, got %#v", local)
+	}
+
+	down := routes[2]
+	if down.Metric != 100 || down.MTU != 1400 || down.Default() {
+		t.Fatalf("expected a down route with metric 100 and MTU 1400, got %#v", down)
+	}
+}
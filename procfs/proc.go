@@ -15,13 +15,18 @@ import (
 )
 
 const (
-	statusName   = "Name"
-	statusPPid   = "PPid"
-	statusState  = "State"
-	statusUid    = "Uid"
-	statusSigBlk = "SigBlk"
-	statusSigIgn = "SigIgn"
-	statusSigCgt = "SigCgt"
+	statusName    = "Name"
+	statusTgid    = "Tgid"
+	statusPid     = "Pid"
+	statusPPid    = "PPid"
+	statusState   = "State"
+	statusUid     = "Uid"
+	statusThreads = "Threads"
+	statusSigPnd  = "SigPnd"
+	statusShdPnd  = "ShdPnd"
+	statusSigBlk  = "SigBlk"
+	statusSigIgn  = "SigIgn"
+	statusSigCgt  = "SigCgt"
 
 	socketLinkRegex = `socket:\[(\d+)\]`
 )
@@ -30,24 +35,47 @@ const (
 type Proc struct {
 	// Process ID
 	Pid int
+
+	fs FS
 }
 
 // Self returns a Proc struct for the current process
 func Self() *Proc {
-	return &Proc{
-		Pid: os.Getpid(),
-	}
+	return defaultFS().Self()
 }
 
 // ProcStatus store data about the process status, as found in /procfs/$PID/status
 type ProcStatus struct {
-	Name   string
-	PPid   int
-	State  string
-	Uid    int
-	SigBlk []syscall.Signal
-	SigIgn []syscall.Signal
-	SigCgt []syscall.Signal
+	Name    string
+	Tgid    int
+	Pid     int
+	PPid    int
+	State   string
+	Uid     int
+	Threads int
+	SigPnd  []syscall.Signal
+	ShdPnd  []syscall.Signal
+	SigBlk  []syscall.Signal
+	SigIgn  []syscall.Signal
+	SigCgt  []syscall.Signal
+}
+
+// ProcStat store data about the process, as found in /procfs/$PID/stat
+type ProcStat struct {
+	Pid        int
+	Comm       string
+	State      string
+	PPid       int
+	PGrp       int
+	Session    int
+	UTime      uint64 // time spent in user mode, in clock ticks
+	STime      uint64 // time spent in kernel mode, in clock ticks
+	CUTime     uint64 // time waited-for children spent in user mode, in clock ticks
+	CSTime     uint64 // time waited-for children spent in kernel mode, in clock ticks
+	NumThreads int
+	StartTime  uint64 // time the process started after system boot, in clock ticks
+	VSize      uint64 // virtual memory size in bytes
+	RSS        int64  // resident set size in pages
 }
 
 //file descriptors are symlinks
@@ -56,13 +84,27 @@ type Fd struct {
 	Target string
 }
 
+// FS returns the proc file system the process belongs to
+func (p *Proc) FS() FS {
+	return p.fs.orDefault()
+}
+
 func (p *Proc) dir() string {
-	return fmt.Sprintf("%s/%d", Mountpoint, p.Pid)
+	return p.FS().Path(strconv.Itoa(p.Pid))
 }
 
 //return ProcStatus of the process
 func (p *Proc) Status() (*ProcStatus, error) {
-	f, err := os.Open(filepath.Join(p.dir(), "status"))
+	return parseStatus(filepath.Join(p.dir(), "status"))
+}
+
+//return ProcStat of the process
+func (p *Proc) Stat() (*ProcStat, error) {
+	return parseStat(filepath.Join(p.dir(), "stat"))
+}
+
+func parseStatus(path string) (*ProcStatus, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -79,12 +121,22 @@ func (p *Proc) Status() (*ProcStatus, error) {
 		switch key {
 		case statusName:
 			s.Name = value
+		case statusTgid:
+			s.Tgid, _ = strconv.Atoi(value)
+		case statusPid:
+			s.Pid, _ = strconv.Atoi(value)
 		case statusPPid:
 			s.PPid, _ = strconv.Atoi(value)
 		case statusState:
 			s.State = value
 		case statusUid:
 			s.Uid, _ = strconv.Atoi(strings.Fields(value)[0])
+		case statusThreads:
+			s.Threads, _ = strconv.Atoi(value)
+		case statusSigPnd:
+			s.SigPnd = decodeSigMask(value)
+		case statusShdPnd:
+			s.ShdPnd = decodeSigMask(value)
 		case statusSigBlk:
 			s.SigBlk = decodeSigMask(value)
 		case statusSigIgn:
@@ -97,10 +149,51 @@ func (p *Proc) Status() (*ProcStatus, error) {
 	return s, scanner.Err()
 }
 
+// parse a stat file, fields are described in man 5 proc
+func parseStat(path string) (*ProcStat, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	//comm is enclosed in parentheses and may contain spaces or parentheses itself
+	data := string(b)
+	l, r := strings.Index(data, "("), strings.LastIndex(data, ")")
+	if l < 0 || r < l {
+		return nil, fmt.Errorf("unexpected stat format in %s", path)
+	}
+
+	s := &ProcStat{
+		Comm: data[l+1 : r],
+	}
+	s.Pid, _ = strconv.Atoi(strings.TrimSpace(data[:l]))
+
+	//fields after comm, starting with field 3 (state)
+	fields := strings.Fields(data[r+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("unexpected stat format in %s", path)
+	}
+
+	s.State = fields[0]
+	s.PPid, _ = strconv.Atoi(fields[1])
+	s.PGrp, _ = strconv.Atoi(fields[2])
+	s.Session, _ = strconv.Atoi(fields[3])
+	s.UTime, _ = strconv.ParseUint(fields[11], 10, 64)
+	s.STime, _ = strconv.ParseUint(fields[12], 10, 64)
+	s.CUTime, _ = strconv.ParseUint(fields[13], 10, 64)
+	s.CSTime, _ = strconv.ParseUint(fields[14], 10, 64)
+	s.NumThreads, _ = strconv.Atoi(fields[17])
+	s.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	s.VSize, _ = strconv.ParseUint(fields[20], 10, 64)
+	s.RSS, _ = strconv.ParseInt(fields[21], 10, 64)
+
+	return s, nil
+}
+
 //return all process's direct children
 func (p *Proc) Children() ([]*Proc, error) {
 	children := []*Proc{}
-	err := WalkProcs(func(process *Proc) (bool, error) {
+	err := p.FS().WalkProcs(func(process *Proc) (bool, error) {
 		if process.Pid == p.Pid { //myself
 			return true, nil
 		}
@@ -144,20 +237,19 @@ func (p *Proc) Descendants() ([]*Proc, error) {
 
 // returns a list of file descriptors as if /proc/$PID/fd
 func (p *Proc) Fds() ([]*Fd, error) {
-	d, err := os.Open(filepath.Join(p.dir(), "fd"))
-	if err != nil {
-		return nil, err
-	}
-	defer d.Close()
-
-	names, err := d.Readdirnames(-1)
+	dir := filepath.Join(p.dir(), "fd")
+	fdNums, err := readNumericDir(dir)
 	if err != nil {
 		return nil, err
 	}
 	fds := []*Fd{}
-	for _, name := range names {
-		targ, err := os.Readlink(filepath.Join(d.Name(), name))
+	for _, fdNum := range fdNums {
+		name := strconv.Itoa(fdNum)
+		targ, err := os.Readlink(filepath.Join(dir, name))
 		if err != nil {
+			if os.IsNotExist(err) {
+				continue //fd closed meanwhile
+			}
 			return nil, err
 		}
 
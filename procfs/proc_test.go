@@ -31,10 +31,8 @@ func TestDecodeSigMask(t *testing.T) {
 }
 
 func TestParseStatusFile(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	p := &Proc{
-		Pid: 1,
-	}
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(1)
 
 	ps, err := p.Status()
 	if err != nil {
@@ -65,10 +63,8 @@ func TestParseStatusFile(t *testing.T) {
 }
 
 func TestFds(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	p := &Proc{
-		Pid: 9,
-	}
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(9)
 	fds, err := p.Fds()
 	if err != nil {
 		t.Fatal(err)
@@ -100,17 +96,15 @@ func TestFds(t *testing.T) {
 }
 
 func TestChildren(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	p := &Proc{
-		Pid: 9,
-	}
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(9)
 	children, err := p.Children()
 	if err != nil {
 		t.Fatal(err)
 	}
 	expected := []*Proc{
-		&Proc{Pid: 12},
-		&Proc{Pid: 14},
+		p.FS().Proc(12),
+		p.FS().Proc(14),
 	}
 	if !reflect.DeepEqual(children, expected) {
 		t.Fatalf("expected processes %#v, got %#v", expected, children)
@@ -118,10 +112,8 @@ func TestChildren(t *testing.T) {
 }
 
 func TestNoChild(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	p := &Proc{
-		Pid: 12,
-	}
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(12)
 	children, err := p.Children()
 	if err != nil {
 		t.Fatal(err)
@@ -132,19 +124,17 @@ func TestNoChild(t *testing.T) {
 }
 
 func TestDescendants(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	p := &Proc{
-		Pid: 1,
-	}
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(1)
 	descendants, err := p.Descendants()
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected := []*Proc{
-		&Proc{Pid: 9},
-		&Proc{Pid: 12},
-		&Proc{Pid: 14},
+		p.FS().Proc(9),
+		p.FS().Proc(12),
+		p.FS().Proc(14),
 	}
 	if !reflect.DeepEqual(descendants, expected) {
 		t.Fatalf("expected processes %#v, got %#v", expected, descendants)
@@ -152,10 +142,8 @@ func TestDescendants(t *testing.T) {
 }
 
 func TestNoDescendant(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	p := &Proc{
-		Pid: 14,
-	}
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(14)
 	descendants, err := p.Descendants()
 	if err != nil {
 		t.Fatal(err)
@@ -164,3 +152,41 @@ func TestNoDescendant(t *testing.T) {
 		t.Fatal("pid 14 should have no descendants")
 	}
 }
+
+func TestParseStatFile(t *testing.T) {
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(14)
+
+	st, err := p.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.Pid != 14 {
+		t.Fatalf("expected Pid 14, got %d", st.Pid)
+	}
+	if st.Comm != "nc" {
+		t.Fatalf("expected Comm nc, got %q", st.Comm)
+	}
+	if st.State != "S" {
+		t.Fatalf("expected State S, got %q", st.State)
+	}
+	if st.PPid != 9 {
+		t.Fatalf("expected PPid 9, got %d", st.PPid)
+	}
+	if st.UTime != 12 || st.STime != 7 {
+		t.Fatalf("expected utime 12 and stime 7, got %d and %d", st.UTime, st.STime)
+	}
+	if st.NumThreads != 2 {
+		t.Fatalf("expected 2 threads, got %d", st.NumThreads)
+	}
+	if st.StartTime != 84312 {
+		t.Fatalf("expected start time 84312, got %d", st.StartTime)
+	}
+	if st.VSize != 14741504 {
+		t.Fatalf("expected vsize 14741504, got %d", st.VSize)
+	}
+	if st.RSS != 129 {
+		t.Fatalf("expected rss 129, got %d", st.RSS)
+	}
+}
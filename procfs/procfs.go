@@ -2,55 +2,120 @@ package procfs
 
 import (
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 )
 
 // DefaultMountpoint define the default mount point of the proc file system
 const DefaultMountpoint = "/proc"
 
-// MountPoint is the path of the proc file system mount point.
-// Default to DefaultMountPoint
+// MountPoint is the path of the proc file system mount point used by the package
+// level functions and by Proc values not created from a FS. Default to DefaultMountPoint
 var Mountpoint = DefaultMountpoint
 
+// FS is a proc file system mounted at a given root. It allows to inspect several
+// proc mounts (for example a container /proc bind-mounted in a sidecar) side by side
+type FS string
+
+// NewFS returns a FS rooted at root
+func NewFS(root string) FS {
+	return FS(root)
+}
+
+// defaultFS returns the FS package level functions are wrapping
+func defaultFS() FS {
+	return FS(Mountpoint)
+}
+
+// orDefault returns fs, or the default FS for zero values (i.e. Proc{Pid: 1})
+func (fs FS) orDefault() FS {
+	if fs == "" {
+		return defaultFS()
+	}
+	return fs
+}
+
+// Path returns the path of the given elements inside the proc file system
+func (fs FS) Path(elem ...string) string {
+	return filepath.Join(append([]string{string(fs)}, elem...)...)
+}
+
+// Proc returns the process with the given pid in the proc file system
+func (fs FS) Proc(pid int) *Proc {
+	return &Proc{
+		Pid: pid,
+		fs:  fs,
+	}
+}
+
+// Self returns the current process in the proc file system
+func (fs FS) Self() *Proc {
+	return fs.Proc(os.Getpid())
+}
+
 // CountRunningProces return the number of running processes or an error if any
-func CountRunningProcs() (int, error) {
+func (fs FS) CountRunningProcs() (int, error) {
 	cpt := 0
-	err := WalkProcs(func(process *Proc) (bool, error) {
+	err := fs.WalkProcs(func(process *Proc) (bool, error) {
 		cpt++
 		return true, nil
 	})
 	return cpt, err
 }
 
+// CountRunningProces return the number of running processes or an error if any
+func CountRunningProcs() (int, error) {
+	return defaultFS().CountRunningProcs()
+}
+
 // WalkFunc WalkFunc is the type of the function called for each process visited by WalkProcs.
 // The process argument contains the current process. If the function return false or an error,
 // the WalkProcs func stop, and returns the eventual error
 type WalkFunc func(process *Proc) (bool, error)
 
+// WalkProcs walks all the processes of the file system and call walk on each process
+func (fs FS) WalkProcs(walk WalkFunc) error {
+	pids, err := readNumericDir(string(fs))
+	if err != nil {
+		return err
+	}
+
+	for _, pid := range pids {
+		loop, err := walk(fs.Proc(pid))
+		if err != nil {
+			return err
+		}
+		if !loop {
+			return nil
+		}
+	}
+	return nil
+}
+
 // WalkProcs walks all the processes and call walk on each process
 func WalkProcs(walk WalkFunc) error {
-	d, err := os.Open(Mountpoint)
+	return defaultFS().WalkProcs(walk)
+}
+
+// readNumericDir returns the sorted list of numerical entries (pids, tids, fds) found in dir
+func readNumericDir(dir string) ([]int, error) {
+	d, err := os.Open(dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer d.Close()
 	names, err := d.Readdirnames(-1)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	pids := []int{}
 	for _, name := range names {
 		if pid, err := strconv.Atoi(name); err == nil {
-			loop, err := walk(&Proc{
-				Pid: pid,
-			})
-			if err != nil {
-				return err
-			}
-			if !loop {
-				return nil
-			}
+			pids = append(pids, pid)
 		}
 	}
-	return nil
+	sort.Ints(pids)
+	return pids, nil
 }
@@ -5,8 +5,8 @@ import (
 )
 
 func TestCountRunningProcs(t *testing.T) {
-	Mountpoint = "./assets/proc"
-	c, err := CountRunningProcs()
+	t.Parallel()
+	c, err := NewFS("./assets/proc").CountRunningProcs()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -14,3 +14,28 @@ func TestCountRunningProcs(t *testing.T) {
 		t.Fatalf("expected 2 running processes, got %d", c)
 	}
 }
+
+func TestFSIsolation(t *testing.T) {
+	t.Parallel()
+	fs := NewFS("./assets/proc")
+	p := fs.Proc(9)
+	if p.FS() != fs {
+		t.Fatalf("expected process to belong to %q, got %q", fs, p.FS())
+	}
+
+	children, err := p.Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range children {
+		if c.FS() != fs {
+			t.Fatalf("expected child %d to belong to %q, got %q", c.Pid, fs, c.FS())
+		}
+	}
+
+	// zero value processes use the package level mount point
+	p = &Proc{Pid: 1}
+	if p.FS() != NewFS(Mountpoint) {
+		t.Fatalf("expected process to belong to %q, got %q", Mountpoint, p.FS())
+	}
+}
@@ -0,0 +1,246 @@
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// userHZ is the number of clock ticks per second used by the kernel to report CPU times
+// (USER_HZ). It is 100 on all supported architectures
+const userHZ = 100
+
+// MemInfo stores the system memory usage, as found in /proc/meminfo. Sizes are in bytes
+type MemInfo struct {
+	MemTotal     uint64
+	MemFree      uint64
+	MemAvailable uint64
+	Buffers      uint64
+	Cached       uint64
+	SwapTotal    uint64
+	SwapFree     uint64
+}
+
+// LoadAvg stores the system load average, as found in /proc/loadavg
+type LoadAvg struct {
+	Load1   float64
+	Load5   float64
+	Load15  float64
+	Running int // number of currently runnable scheduling entities
+	Total   int // number of scheduling entities that currently exist on the system
+	LastPid int
+}
+
+// CPUStat stores the time spent by a CPU in various states, in clock ticks
+type CPUStat struct {
+	User      uint64
+	Nice      uint64
+	System    uint64
+	Idle      uint64
+	IOWait    uint64
+	IRQ       uint64
+	SoftIRQ   uint64
+	Steal     uint64
+	Guest     uint64
+	GuestNice uint64
+}
+
+// SystemStat stores kernel and system statistics, as found in /proc/stat
+type SystemStat struct {
+	CPU              CPUStat   // aggregated over all CPUs
+	CPUs             []CPUStat // per CPU
+	BootTime         time.Time
+	ContextSwitches  uint64
+	ProcessesCreated uint64
+	ProcsRunning     int
+	ProcsBlocked     int
+}
+
+// SystemUptime stores the system uptime and the time spent idle (summed over all CPUs),
+// as found in /proc/uptime
+type SystemUptime struct {
+	Uptime time.Duration
+	Idle   time.Duration
+}
+
+// ReadMemInfo returns the memory usage found in the file system
+func (fs FS) ReadMemInfo() (*MemInfo, error) {
+	f, err := os.Open(fs.Path("meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &MemInfo{}
+	fields := map[string]*uint64{
+		"MemTotal":     &m.MemTotal,
+		"MemFree":      &m.MemFree,
+		"MemAvailable": &m.MemAvailable,
+		"Buffers":      &m.Buffers,
+		"Cached":       &m.Cached,
+		"SwapTotal":    &m.SwapTotal,
+		"SwapFree":     &m.SwapFree,
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		records := strings.SplitN(scanner.Text(), ":", 2)
+		if len(records) != 2 {
+			continue
+		}
+		field, ok := fields[records[0]]
+		if !ok {
+			continue
+		}
+		//values are in the form "8052704 kB"
+		value := strings.Fields(records[1])
+		if len(value) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(value[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid meminfo value for %s: %v", records[0], err)
+		}
+		if len(value) > 1 && value[1] == "kB" {
+			v *= 1024
+		}
+		*field = v
+	}
+	return m, scanner.Err()
+}
+
+// ReadMemInfo returns the system memory usage
+func ReadMemInfo() (*MemInfo, error) {
+	return defaultFS().ReadMemInfo()
+}
+
+// ReadLoadAvg returns the load average found in the file system
+func (fs FS) ReadLoadAvg() (*LoadAvg, error) {
+	b, err := ioutil.ReadFile(fs.Path("loadavg"))
+	if err != nil {
+		return nil, err
+	}
+
+	//format: 0.52 0.58 0.59 2/389 24193
+	fields := strings.Fields(string(b))
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected loadavg format: %q", string(b))
+	}
+	entities := strings.SplitN(fields[3], "/", 2)
+	if len(entities) != 2 {
+		return nil, fmt.Errorf("unexpected loadavg format: %q", string(b))
+	}
+
+	l := &LoadAvg{}
+	l.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	l.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	l.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	l.Running, _ = strconv.Atoi(entities[0])
+	l.Total, _ = strconv.Atoi(entities[1])
+	l.LastPid, _ = strconv.Atoi(fields[4])
+	return l, nil
+}
+
+// ReadLoadAvg returns the system load average
+func ReadLoadAvg() (*LoadAvg, error) {
+	return defaultFS().ReadLoadAvg()
+}
+
+// ReadStat returns the kernel and system statistics found in the file system
+func (fs FS) ReadStat() (*SystemStat, error) {
+	f, err := os.Open(fs.Path("stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &SystemStat{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch key := fields[0]; {
+		case key == "cpu":
+			s.CPU = parseCPUStat(fields[1:])
+		case strings.HasPrefix(key, "cpu"):
+			s.CPUs = append(s.CPUs, parseCPUStat(fields[1:]))
+		case key == "btime":
+			btime, _ := strconv.ParseInt(fields[1], 10, 64)
+			s.BootTime = time.Unix(btime, 0)
+		case key == "ctxt":
+			s.ContextSwitches, _ = strconv.ParseUint(fields[1], 10, 64)
+		case key == "processes":
+			s.ProcessesCreated, _ = strconv.ParseUint(fields[1], 10, 64)
+		case key == "procs_running":
+			s.ProcsRunning, _ = strconv.Atoi(fields[1])
+		case key == "procs_blocked":
+			s.ProcsBlocked, _ = strconv.Atoi(fields[1])
+		}
+	}
+	return s, scanner.Err()
+}
+
+// ReadStat returns the kernel and system statistics
+func ReadStat() (*SystemStat, error) {
+	return defaultFS().ReadStat()
+}
+
+// parse the columns of a cpu line, older kernels report less columns
+func parseCPUStat(columns []string) CPUStat {
+	c := CPUStat{}
+	for i, field := range []*uint64{&c.User, &c.Nice, &c.System, &c.Idle, &c.IOWait, &c.IRQ, &c.SoftIRQ, &c.Steal, &c.Guest, &c.GuestNice} {
+		if i >= len(columns) {
+			break
+		}
+		*field, _ = strconv.ParseUint(columns[i], 10, 64)
+	}
+	return c
+}
+
+// Total returns the total time spent by the CPU, in clock ticks. Guest times are already
+// accounted in user times
+func (c CPUStat) Total() uint64 {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
+}
+
+// ReadUptime returns the uptime found in the file system
+func (fs FS) ReadUptime() (*SystemUptime, error) {
+	b, err := ioutil.ReadFile(fs.Path("uptime"))
+	if err != nil {
+		return nil, err
+	}
+
+	//format: 350735.47 1352137.13
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected uptime format: %q", string(b))
+	}
+
+	u := &SystemUptime{}
+	for i, field := range []*time.Duration{&u.Uptime, &u.Idle} {
+		d, err := time.ParseDuration(fields[i] + "s")
+		if err != nil {
+			return nil, fmt.Errorf("unexpected uptime format: %q", string(b))
+		}
+		*field = d
+	}
+	return u, nil
+}
+
+// ReadUptime returns the system uptime
+func ReadUptime() (*SystemUptime, error) {
+	return defaultFS().ReadUptime()
+}
+
+// TicksToDuration converts clock ticks, as reported in stat files, into a duration
+func TicksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * time.Second / userHZ
+}
@@ -0,0 +1,85 @@
+package procfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadMemInfo(t *testing.T) {
+	t.Parallel()
+	m, err := NewFS("./assets/proc").ReadMemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MemTotal != 8052704*1024 {
+		t.Fatalf("expected MemTotal %d, got %d", 8052704*1024, m.MemTotal)
+	}
+	if m.MemAvailable != 4821632*1024 {
+		t.Fatalf("expected MemAvailable %d, got %d", 4821632*1024, m.MemAvailable)
+	}
+	if m.SwapFree != 2031356*1024 {
+		t.Fatalf("expected SwapFree %d, got %d", 2031356*1024, m.SwapFree)
+	}
+}
+
+func TestReadLoadAvg(t *testing.T) {
+	t.Parallel()
+	l, err := NewFS("./assets/proc").ReadLoadAvg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := LoadAvg{Load1: 0.52, Load5: 0.58, Load15: 0.59, Running: 2, Total: 389, LastPid: 24193}
+	if *l != expected {
+		t.Fatalf("expected load average %#v, got %#v", expected, *l)
+	}
+}
+
+func TestReadStat(t *testing.T) {
+	t.Parallel()
+	s, err := NewFS("./assets/proc").ReadStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCPU := CPUStat{User: 2255634, Nice: 3217, System: 583457, Idle: 134312596, IOWait: 66313, SoftIRQ: 13428}
+	if s.CPU != expectedCPU {
+		t.Fatalf("expected cpu %#v, got %#v", expectedCPU, s.CPU)
+	}
+	if len(s.CPUs) != 4 {
+		t.Fatalf("expected 4 cpus, got %d", len(s.CPUs))
+	}
+	if s.CPUs[3].User != 560041 {
+		t.Fatalf("expected cpu3 user time 560041, got %d", s.CPUs[3].User)
+	}
+	if !s.BootTime.Equal(time.Unix(1460364373, 0)) {
+		t.Fatalf("expected boot time %v, got %v", time.Unix(1460364373, 0), s.BootTime)
+	}
+	if s.ContextSwitches != 368224735 {
+		t.Fatalf("expected 368224735 context switches, got %d", s.ContextSwitches)
+	}
+	if s.ProcessesCreated != 24198 {
+		t.Fatalf("expected 24198 processes created, got %d", s.ProcessesCreated)
+	}
+	if s.ProcsRunning != 2 || s.ProcsBlocked != 1 {
+		t.Fatalf("expected 2 procs running and 1 blocked, got %d and %d", s.ProcsRunning, s.ProcsBlocked)
+	}
+}
+
+func TestReadUptime(t *testing.T) {
+	t.Parallel()
+	u, err := NewFS("./assets/proc").ReadUptime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Uptime != 350735470*time.Millisecond {
+		t.Fatalf("expected uptime %v, got %v", 350735470*time.Millisecond, u.Uptime)
+	}
+	if u.Idle != 1352137130*time.Millisecond {
+		t.Fatalf("expected idle %v, got %v", 1352137130*time.Millisecond, u.Idle)
+	}
+}
+
+func TestTicksToDuration(t *testing.T) {
+	if d := TicksToDuration(250); d != 2500*time.Millisecond {
+		t.Fatalf("expected 2.5s, got %v", d)
+	}
+}
@@ -0,0 +1,49 @@
+package procfs
+
+import (
+	"path/filepath"
+	"strconv"
+)
+
+// Task provides information about a thread of a running process, as found in /proc/$PID/task/$TID
+type Task struct {
+	// Process ID the thread belongs to
+	Pid int
+	// Thread ID
+	Tid int
+
+	fs FS
+}
+
+func (t *Task) dir() string {
+	return t.fs.orDefault().Path(strconv.Itoa(t.Pid), "task", strconv.Itoa(t.Tid))
+}
+
+// return all the threads of the process, the main thread included
+func (p *Proc) Tasks() ([]*Task, error) {
+	tids, err := readNumericDir(filepath.Join(p.dir(), "task"))
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []*Task{}
+	for _, tid := range tids {
+		tasks = append(tasks, &Task{
+			Pid: p.Pid,
+			Tid: tid,
+			fs:  p.FS(),
+		})
+	}
+	return tasks, nil
+}
+
+// return ProcStatus of the thread. SigBlk and SigPnd are specific to the thread,
+// other signal masks are shared by all the threads of the process
+func (t *Task) Status() (*ProcStatus, error) {
+	return parseStatus(filepath.Join(t.dir(), "status"))
+}
+
+// return ProcStat of the thread
+func (t *Task) Stat() (*ProcStat, error) {
+	return parseStat(filepath.Join(t.dir(), "stat"))
+}
@@ -0,0 +1,77 @@
+package procfs
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestTasks(t *testing.T) {
+	t.Parallel()
+	p := NewFS("./assets/proc").Proc(14)
+	tasks, err := p.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Task{
+		&Task{Pid: 14, Tid: 14, fs: p.FS()},
+		&Task{Pid: 14, Tid: 15, fs: p.FS()},
+	}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Fatalf("expected tasks %#v, got %#v", expected, tasks)
+	}
+}
+
+func TestTaskStatus(t *testing.T) {
+	t.Parallel()
+	task := &Task{
+		Pid: 14,
+		Tid: 15,
+		fs:  NewFS("./assets/proc"),
+	}
+	ts, err := task.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Pid != 15 {
+		t.Fatalf("expected Pid 15, got %d", ts.Pid)
+	}
+	if ts.Tgid != 14 {
+		t.Fatalf("expected Tgid 14, got %d", ts.Tgid)
+	}
+	if !reflect.DeepEqual(ts.SigBlk, []syscall.Signal{syscall.SIGTERM}) {
+		t.Fatalf("expected thread to block SIGTERM only, got %v", ts.SigBlk)
+	}
+
+	//main thread doesn't block anything
+	task.Tid = 14
+	ts, err = task.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.SigBlk) != 0 {
+		t.Fatalf("expected main thread to block no signal, got %v", ts.SigBlk)
+	}
+}
+
+func TestTaskStat(t *testing.T) {
+	t.Parallel()
+	task := &Task{
+		Pid: 14,
+		Tid: 15,
+		fs:  NewFS("./assets/proc"),
+	}
+	st, err := task.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Pid != 15 {
+		t.Fatalf("expected Pid 15, got %d", st.Pid)
+	}
+	if st.Comm != "nc worker" {
+		t.Fatalf("expected Comm \"nc worker\", got %q", st.Comm)
+	}
+	if st.UTime != 3 || st.STime != 2 {
+		t.Fatalf("expected utime 3 and stime 2, got %d and %d", st.UTime, st.STime)
+	}
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/robinmonjo/dock/journal"
+	"github.com/robinmonjo/dock/notifier"
 	"github.com/robinmonjo/dock/procfs"
 )
 
@@ -19,9 +26,43 @@ const (
 	killTimeout      = 5
 )
 
+var signalNames = map[string]syscall.Signal{
+	"ABRT":  syscall.SIGABRT,
+	"ALRM":  syscall.SIGALRM,
+	"BUS":   syscall.SIGBUS,
+	"CHLD":  syscall.SIGCHLD,
+	"CONT":  syscall.SIGCONT,
+	"FPE":   syscall.SIGFPE,
+	"HUP":   syscall.SIGHUP,
+	"ILL":   syscall.SIGILL,
+	"INT":   syscall.SIGINT,
+	"KILL":  syscall.SIGKILL,
+	"PIPE":  syscall.SIGPIPE,
+	"QUIT":  syscall.SIGQUIT,
+	"SEGV":  syscall.SIGSEGV,
+	"STOP":  syscall.SIGSTOP,
+	"SYS":   syscall.SIGSYS,
+	"TERM":  syscall.SIGTERM,
+	"TRAP":  syscall.SIGTRAP,
+	"TSTP":  syscall.SIGTSTP,
+	"TTIN":  syscall.SIGTTIN,
+	"TTOU":  syscall.SIGTTOU,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"WINCH": syscall.SIGWINCH,
+	"XCPU":  syscall.SIGXCPU,
+	"XFSZ":  syscall.SIGXFSZ,
+}
+
 type signalsHandler struct {
 	signals   chan os.Signal
 	authority bool
+
+	cmdlines cmdlines
+	reaped   []exit //orphans reaped, most recent last
+
+	mutex    sync.Mutex
+	commands map[int]chan exit //commands run by dock itself (see run), by pid
 }
 
 func newSignalsHandler() *signalsHandler {
@@ -29,50 +70,66 @@ func newSignalsHandler() *signalsHandler {
 	signal.Notify(s)
 
 	return &signalsHandler{
-		signals: s,
+		signals:  s,
+		cmdlines: cmdlines{},
+		commands: map[int]chan exit{},
 	}
 }
 
-func (h *signalsHandler) forward(p *process) int {
+func (h *signalsHandler) forward(p *process) exit {
 
 	pid1 := p.pid()
+	h.cmdlines[pid1] = p.argv
 
 	for s := range h.signals {
-		log.Debugf("signal: %q", s)
+		signalsLog.WithField("signal", osSignalName(s)).Debug("signal received")
 
 		switch s {
 		case syscall.SIGWINCH:
 			p.resizePty()
 
 		case syscall.SIGCHLD:
-			//child process died, dock will exit
+			//reap the children that died. If the process is not among them, an orphan
+			//process adopted by dock died and the process keeps running
+			exits, err := h.reap(syscall.WNOHANG, pid1)
+			if err != nil {
+				signalsLog.Error(err)
+			}
+			e, died := findExit(exits, pid1)
+			if !died {
+				h.cmdlines.snapshot()
+				continue
+			}
+			signalsLog.WithField("pid", e.pid).Debug(e)
+			h.cmdlines.snapshot()
+
+			//child process died, dock will exit (or restart the process)
 			//sending sigterm to every remaining processes before calling wait4
 			if err := signalAllDescendants(syscall.SIGTERM); err != nil {
-				log.Debugf("failed to send sigterm signal: %v", err)
+				signalsLog.WithField("signal", "SIGTERM").Debugf("failed to signal descendants: %v", err)
 			}
 
-			go func() {
-				<-time.After(killTimeout * time.Second)
-				log.Debugf("kill timed out")
+			killTimer := time.AfterFunc(killTimeout*time.Second, func() {
+				signalsLog.Debug("kill timed out")
 				if err := signalAllDescendants(syscall.SIGKILL); err != nil {
-					log.Debugf("failed to send sigkill signal: %v", err)
+					signalsLog.WithField("signal", "SIGKILL").Debugf("failed to signal descendants: %v", err)
 				}
-			}()
+			})
 
 			//waiting for all processes to die
-			log.Debug("reaping all children")
-			exits, err := reap()
-			log.Debug("children reaped")
-			if err != nil {
-				log.Error(err)
+			signalsLog.Debug("reaping all children")
+			if _, err := h.reap(0, pid1); err != nil {
+				signalsLog.Error(err)
 			}
+			signalsLog.Debug("children reaped")
+			killTimer.Stop()
 
-			for _, e := range exits {
-				if e.pid == pid1 {
-					p.wait()
-					return e.status
-				}
-			}
+			p.wait()
+			return e
+
+		case syscall.SIGPIPE:
+			//raised by dock's own writes (i.e. to a notification command that doesn't read its
+			//stdin), not meant for the process
 
 		case syscall.SIGINT:
 			fallthrough
@@ -81,53 +138,102 @@ func (h *signalsHandler) forward(p *process) int {
 		case syscall.SIGQUIT:
 			//stopping signals
 			sigToForward := s
+			processStateChanged(&notifier.Ps{
+				Status:  notifier.StatusStopping,
+				Message: fmt.Sprintf("%s received", signalName(s.(syscall.Signal))),
+			})
 
 			if h.authority {
 				blocked, err := isSignalBlocked(pid1, s)
 				if err != nil {
-					log.Error(err)
+					signalsLog.WithField("pid", pid1).Error(err)
 					goto forward
 				}
 				ignored, err := isSignalIgnored(pid1, s)
 				if err != nil {
-					log.Error(err)
+					signalsLog.WithField("pid", pid1).Error(err)
 					goto forward
 				}
 				if blocked || ignored {
 					sigToForward = os.Signal(syscall.SIGKILL)
+					how := "ignored"
+					if blocked {
+						how = "blocked"
+					}
+					record(&journal.Event{
+						Type:      journal.TypeEscalation,
+						Pid:       pid1,
+						Signal:    signalName(s.(syscall.Signal)),
+						Forwarded: signalName(syscall.SIGKILL),
+						Message:   fmt.Sprintf("%s %s by the process", signalName(s.(syscall.Signal)), how),
+					})
 				}
 			}
 
 		forward:
-			if err := p.signal(sigToForward); err != nil {
-				log.Error(err)
-			}
+			forwardSignal(p, s, sigToForward)
 
 		default:
 
 			//simply forward the signal to the process
-			if err := p.signal(s); err != nil {
-				log.Error(err)
-			}
+			forwardSignal(p, s, s)
 		}
 	}
 
 	panic("-- this line should never been executed --")
 }
 
-// exit models a process exit status with the pid and exit status.
-type exit struct {
-	pid    int
-	status int
+// handle a signal received while an init step runs, the process isn't started yet. Signals are
+// passed on to the process group of the step, returns whether it's a stopping one
+func (h *signalsHandler) forwardInit(s os.Signal, pgid int) (stopping bool) {
+	signalsLog.WithField("signal", osSignalName(s)).Debug("signal received")
+
+	switch s {
+	case syscall.SIGCHLD:
+		//orphans adopted by dock, the step exit is handed over to its waiter
+		if _, err := h.reap(syscall.WNOHANG, 0); err != nil {
+			signalsLog.Error(err)
+		}
+		h.cmdlines.snapshot()
+		return false
+
+	case syscall.SIGWINCH, syscall.SIGPIPE:
+		return false
+
+	case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
+		stopping = true
+	}
+
+	if err := syscall.Kill(-pgid, s.(syscall.Signal)); err != nil {
+		signalsLog.WithFields(log.Fields{"pid": pgid, "signal": osSignalName(s)}).Error(err)
+	}
+	return stopping
+}
+
+// send the signal received (or its translation) to the process, count and record it
+func forwardSignal(p *process, received, sent os.Signal) {
+	if err := p.signal(sent); err != nil {
+		signalsLog.WithFields(log.Fields{"pid": p.pid(), "signal": osSignalName(sent)}).Error(err)
+		return
+	}
+	signalsForwarded.With(osSignalName(sent)).Inc()
+	record(&journal.Event{
+		Type:      journal.TypeSignal,
+		Pid:       p.pid(),
+		Signal:    osSignalName(received),
+		Forwarded: osSignalName(sent),
+	})
 }
 
-func reap() (exits []exit, err error) {
+// reap children until none is left. With syscall.WNOHANG, stop as soon as remaining children are
+// still running. Orphans (any child but pid1) are recorded in h.reaped
+func (h *signalsHandler) reap(options int, pid1 int) (exits []exit, err error) {
 	var (
 		ws  syscall.WaitStatus
 		rus syscall.Rusage
 	)
 	for {
-		pid, err := syscall.Wait4(-1, &ws, 0, &rus)
+		pid, err := syscall.Wait4(-1, &ws, options, &rus)
 		if err != nil {
 			if err == syscall.ECHILD || err == syscall.ESRCH {
 				return exits, nil
@@ -137,12 +243,75 @@ func reap() (exits []exit, err error) {
 		if pid <= 0 {
 			return exits, nil
 		}
-		log.Debugf("process with PID %d died", pid)
-		exits = append(exits, exit{
-			pid:    pid,
-			status: exitStatus(ws),
-		})
+		e := newExit(pid, ws, &rus)
+		e.argv = h.cmdlines.pop(pid)
+
+		h.mutex.Lock()
+		c, isCommand := h.commands[pid]
+		h.mutex.Unlock()
+		if isCommand {
+			c <- e
+			continue
+		}
+
+		exits = append(exits, e)
+
+		if pid != pid1 {
+			signalsLog.WithField("pid", pid).Debugf("orphan %s", e)
+			orphansReaped.Inc()
+			record(&journal.Event{Type: journal.TypeOrphan, Pid: pid, Exit: e.info()})
+			h.reaped = append(h.reaped, e)
+			if len(h.reaped) > maxReapedExits {
+				h.reaped = h.reaped[1:]
+			}
+		}
+	}
+}
+
+// start a command of dock itself (i.e. a notification command or an init step), the returned
+// function waits for it. The command may be reaped by reap before Wait is called, its exit is
+// then handed over instead of being recorded as an orphan's
+func (h *signalsHandler) start(cmd *exec.Cmd) (func() error, error) {
+	h.mutex.Lock()
+	if err := cmd.Start(); err != nil {
+		h.mutex.Unlock()
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+	c := make(chan exit, 1)
+	h.commands[pid] = c
+	h.mutex.Unlock()
+
+	wait := func() error {
+		defer func() {
+			h.mutex.Lock()
+			delete(h.commands, pid)
+			h.mutex.Unlock()
+		}()
+
+		err := cmd.Wait()
+		if se, ok := err.(*os.SyscallError); !ok || se.Err != syscall.ECHILD {
+			return err
+		}
+		e := <-c
+		if e.signal != 0 {
+			return fmt.Errorf("killed by %s", signalName(e.signal))
+		}
+		if e.code != 0 {
+			return fmt.Errorf("exit status %d", e.code)
+		}
+		return nil
 	}
+	return wait, nil
+}
+
+func findExit(exits []exit, pid int) (exit, bool) {
+	for _, e := range exits {
+		if e.pid == pid {
+			return e, true
+		}
+	}
+	return exit{}, false
 }
 
 // Send the given signal to every processes except for the PID 1
@@ -158,13 +327,33 @@ func signalAllDescendants(sig syscall.Signal) error {
 	return err
 }
 
-// tell if given pid blocks the given signal
+// tell if given pid blocks the given signal. Signal masks are per thread and a signal sent
+// to the process is delivered to any thread not blocking it, so the signal is considered
+// blocked only if every thread blocks it
 func isSignalBlocked(pid int, s os.Signal) (bool, error) {
-	status, err := procStatus(pid)
+	p := &procfs.Proc{
+		Pid: pid,
+	}
+	tasks, err := p.Tasks()
 	if err != nil {
 		return false, err
 	}
-	return include(status.SigBlk, s), nil
+
+	blocked := false
+	for _, t := range tasks {
+		status, err := t.Status()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue //thread exited meanwhile
+			}
+			return false, err
+		}
+		if !include(status.SigBlk, s) {
+			return false, nil
+		}
+		blocked = true
+	}
+	return blocked, nil
 }
 
 // tell if the given pid ignore the given signal
@@ -194,3 +383,36 @@ func include(signals []syscall.Signal, s os.Signal) bool {
 	}
 	return false
 }
+
+// returns the name of the signal (i.e. SIGTERM)
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return fmt.Sprintf("SIG%d", int(sig))
+}
+
+// i.e. SIGTERM, for signals that may not be syscall signals
+func osSignalName(s os.Signal) string {
+	if sig, ok := s.(syscall.Signal); ok {
+		return signalName(sig)
+	}
+	return s.String()
+}
+
+// parse a signal name (TERM, SIGTERM, sigterm) or number (15)
+func parseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n <= 0 || n > 64 {
+			return 0, fmt.Errorf("invalid signal number %d", n)
+		}
+		return syscall.Signal(n), nil
+	}
+	sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal %q", name)
+	}
+	return sig, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/robinmonjo/dock/notifier"
+)
+
+// stateFile writes the state of the process to the file given to --state-file on every
+// transition, for tools that don't speak HTTP
+type stateFile struct {
+	path     string
+	lastExit *notifier.Exit //kept across restarts
+}
+
+// content of the state file
+type processState struct {
+	State           notifier.PsStatus `json:"state"`
+	Message         string            `json:"message,omitempty"`
+	Pid             int               `json:"pid,omitempty"` //0 while the process isn't running
+	Argv            []string          `json:"argv"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	Restarts        int               `json:"restarts"`
+	ReadinessSource string            `json:"readiness_source"`
+	Port            *notifier.Port    `json:"port,omitempty"`
+	LastExit        *notifier.Exit    `json:"last_exit,omitempty"`
+	DockPid         int               `json:"dock_pid"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+func newStateFile(path string) (*stateFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &stateFile{path: path}, nil
+}
+
+// write the state the process moved to. Must be called in transitions order
+func (f *stateFile) write(p *process, ps *notifier.Ps) error {
+	if ps.Exit != nil {
+		f.lastExit = ps.Exit
+	}
+
+	desc := &notifier.Ps{}
+	p.describe(desc)
+	s := &processState{
+		State:           ps.Status,
+		Message:         ps.Message,
+		Pid:             p.runningPid(),
+		Argv:            desc.Argv,
+		StartedAt:       desc.StartedAt,
+		Restarts:        desc.Restarts,
+		ReadinessSource: readinessSource(p),
+		Port:            desc.Port,
+		LastExit:        f.lastExit,
+		DockPid:         os.Getpid(),
+		UpdatedAt:       time.Now().UTC(),
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, b, 0644)
+}
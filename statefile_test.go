@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robinmonjo/dock/health"
+	"github.com/robinmonjo/dock/notifier"
+)
+
+func readStateFile(t *testing.T, path string) *processState {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &processState{}
+	if err := json.Unmarshal(b, s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// missing directories are created
+	path := filepath.Join(dir, "run", "dock", "state.json")
+	f, err := newStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startedAt := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := &process{
+		argv:      []string{"/bin/app", "--serve"},
+		bindPort:  "8080",
+		running:   true,
+		startedAt: startedAt,
+		startPid:  1234,
+		restarts:  2,
+		port:      &notifier.Port{Port: "8080", Bound: true},
+	}
+	before := time.Now().UTC()
+	if err := f.write(p, &notifier.Ps{Status: notifier.StatusReady}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := readStateFile(t, path)
+	if s.UpdatedAt.Before(before.Add(-time.Second)) {
+		t.Fatalf("unexpected update time %v", s.UpdatedAt)
+	}
+	s.UpdatedAt = time.Time{}
+	expected := &processState{
+		State:           notifier.StatusReady,
+		Pid:             1234,
+		Argv:            []string{"/bin/app", "--serve"},
+		StartedAt:       &startedAt,
+		Restarts:        2,
+		ReadinessSource: health.ReadyOnBindPort,
+		Port:            &notifier.Port{Port: "8080", Bound: true},
+		DockPid:         os.Getpid(),
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, s)
+	}
+
+	// the pid is omitted once exited, the exit is kept across restarts
+	p.running = false
+	p.port = nil
+	exit := &notifier.Exit{Pid: 1234, Argv: p.argv, Status: 143, Signal: "SIGTERM"}
+	if err := f.write(p, &notifier.Ps{Status: notifier.StatusKilled, Message: "killed by SIGTERM", Exit: exit}); err != nil {
+		t.Fatal(err)
+	}
+	s = readStateFile(t, path)
+	if s.State != notifier.StatusKilled || s.Message != "killed by SIGTERM" || s.Pid != 0 || !reflect.DeepEqual(s.LastExit, exit) {
+		t.Fatalf("unexpected exited state %+v", s)
+	}
+
+	if err := f.write(p, &notifier.Ps{Status: notifier.StatusRestarting}); err != nil {
+		t.Fatal(err)
+	}
+	s = readStateFile(t, path)
+	if s.State != notifier.StatusRestarting || !reflect.DeepEqual(s.LastExit, exit) {
+		t.Fatalf("expected the last exit to be kept, got %+v", s)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0644 {
+		t.Fatalf("expected mode 0644, got %v", perm)
+	}
+	// written atomically, no temporary file left behind
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected the state file only, got %d files", len(files))
+	}
+}
@@ -0,0 +1,77 @@
+// Package tlsconfig builds TLS client configurations from user options (CA bundle, client
+// certificate, server name and minimum version)
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// Options describe how a TLS client verifies servers and authenticates itself. The zero value
+// verifies servers against the system roots
+type Options struct {
+	CAFile     string //PEM encoded CA bundle used to verify servers, system roots if empty
+	CertFile   string //PEM encoded client certificate (mutual TLS)
+	KeyFile    string //PEM encoded client private key
+	ServerName string //name verified in server certificates, defaults to the host dialed
+	MinVersion string //minimum TLS version: 1.0, 1.1, or 1.2 (default)
+	Insecure   bool   //skip server verification, must be explicitly requested
+}
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
+// Client returns the TLS configuration of a client
+func (o *Options) Client() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.Insecure,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if o.MinVersion != "" {
+		v, err := ParseVersion(o.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		config.MinVersion = v
+	}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA bundle %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, fmt.Errorf("both a client certificate and a key must be given")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// ParseVersion returns the TLS version constant of a version such as "1.2"
+func ParseVersion(v string) (uint16, error) {
+	version, ok := versions[v]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, expected 1.0, 1.1 or 1.2", v)
+	}
+	return version, nil
+}
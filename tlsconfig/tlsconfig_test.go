@@ -0,0 +1,235 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pki stores the paths of a locally generated CA, server and client certificates
+type pki struct {
+	dir            string
+	caFile         string
+	serverCert     tls.Certificate
+	clientCertFile string
+	clientKeyFile  string
+	otherCAFile    string //CA that didn't sign anything
+	serverName     string //only name in the server certificate
+	clientCAs      *x509.CertPool
+}
+
+func newPKI(t *testing.T) *pki {
+	dir, err := ioutil.TempDir("", "dock-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &pki{dir: dir, serverName: "dock.test"}
+
+	ca, caKey := generate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "dock test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	p.caFile = p.write(t, "ca.pem", "CERTIFICATE", ca.Raw)
+	p.clientCAs = x509.NewCertPool()
+	p.clientCAs.AddCert(ca)
+
+	other, _ := generate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "other CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	p.otherCAFile = p.write(t, "other.pem", "CERTIFICATE", other.Raw)
+
+	server, serverKey := generate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: p.serverName},
+		DNSNames:    []string{p.serverName},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	p.serverCert = tls.Certificate{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}
+
+	client, clientKey := generate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "dock"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	p.clientCertFile = p.write(t, "client.pem", "CERTIFICATE", client.Raw)
+	der, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.clientKeyFile = p.write(t, "client-key.pem", "EC PRIVATE KEY", der)
+	return p
+}
+
+// generate a certificate signed by parent, self signed if parent is nil
+func generate(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func (p *pki) write(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(p.dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serve accepts TLS connections requiring a client certificate, writes "ok" once the handshake succeeds
+func (p *pki) serve(t *testing.T, maxVersion uint16) net.Listener {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{p.serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    p.clientCAs,
+		MinVersion:   tls.VersionTLS10,
+		MaxVersion:   maxVersion,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if err := conn.(*tls.Conn).Handshake(); err == nil {
+					conn.Write([]byte("ok"))
+				}
+			}(conn)
+		}
+	}()
+	return ln
+}
+
+// dial the server and read its greeting
+func dial(addr string, o *Options) error {
+	config, err := o.Client()
+	if err != nil {
+		return err
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", addr, config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+	if string(b) != "ok" {
+		return fmt.Errorf("unexpected greeting %q", b)
+	}
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	p := newPKI(t)
+	defer os.RemoveAll(p.dir)
+
+	ln := p.serve(t, 0)
+	defer ln.Close()
+	addr := ln.Addr().String()
+
+	tests := []struct {
+		name    string
+		options Options
+		ok      bool
+	}{
+		{"mutual TLS", Options{CAFile: p.caFile, CertFile: p.clientCertFile, KeyFile: p.clientKeyFile, ServerName: p.serverName}, true},
+		{"no client certificate", Options{CAFile: p.caFile, ServerName: p.serverName}, false},
+		{"unknown authority", Options{CAFile: p.otherCAFile, CertFile: p.clientCertFile, KeyFile: p.clientKeyFile, ServerName: p.serverName}, false},
+		{"system roots", Options{CertFile: p.clientCertFile, KeyFile: p.clientKeyFile, ServerName: p.serverName}, false},
+		{"name mismatch", Options{CAFile: p.caFile, CertFile: p.clientCertFile, KeyFile: p.clientKeyFile}, false},
+		{"insecure", Options{CertFile: p.clientCertFile, KeyFile: p.clientKeyFile, Insecure: true}, true},
+	}
+	for _, test := range tests {
+		err := dial(addr, &test.options)
+		if test.ok && err != nil {
+			t.Fatalf("%s: expected success, got %v", test.name, err)
+		}
+		if !test.ok && err == nil {
+			t.Fatalf("%s: expected failure", test.name)
+		}
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	p := newPKI(t)
+	defer os.RemoveAll(p.dir)
+
+	ln := p.serve(t, tls.VersionTLS11)
+	defer ln.Close()
+
+	o := &Options{CAFile: p.caFile, CertFile: p.clientCertFile, KeyFile: p.clientKeyFile, ServerName: p.serverName}
+	if err := dial(ln.Addr().String(), o); err == nil {
+		t.Fatal("expected failure with a server limited to TLS 1.1")
+	}
+	o.MinVersion = "1.1"
+	if err := dial(ln.Addr().String(), o); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	p := newPKI(t)
+	defer os.RemoveAll(p.dir)
+
+	for _, o := range []Options{
+		{MinVersion: "1.3"},
+		{CertFile: p.clientCertFile}, //no key
+		{CAFile: p.clientKeyFile},    //no certificate
+		{CAFile: filepath.Join(p.dir, "missing.pem")},
+	} {
+		if _, err := o.Client(); err == nil {
+			t.Fatalf("expected an error for %#v", o)
+		}
+	}
+
+	config, err := (&Options{}).Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.InsecureSkipVerify || config.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("expected a verifying TLS 1.2+ default configuration, got %#v", config)
+	}
+}
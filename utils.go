@@ -1,13 +1,21 @@
 package main
 
 import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
 	"github.com/robinmonjo/dock/iowire"
 	"github.com/robinmonjo/dock/procfs"
+	"github.com/robinmonjo/dock/tlsconfig"
 )
 
 const exitSignalOffset = 128
@@ -53,3 +61,83 @@ func parsePrefixArg(prefix string) (string, iowire.Color) {
 	}
 	return comps[0], iowire.MapColor(comps[len(comps)-1])
 }
+
+// parse a size in bytes with an optional binary unit suffix (i.e. 512M, 1G, 2048KB)
+func parseSize(size string) (uint64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := uint64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:n-1]
+		}
+	}
+
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return v * multiplier, nil
+}
+
+// TLS client flags of a connection, prefixed by its name (i.e. --io-tls-ca)
+func tlsFlags(prefix, connection string) []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: prefix + "-tls-ca", Usage: "PEM CA bundle used to verify the " + connection + " server (system roots by default)"},
+		cli.StringFlag{Name: prefix + "-tls-cert", Usage: "PEM client certificate presented to the " + connection + " server (mutual TLS)"},
+		cli.StringFlag{Name: prefix + "-tls-key", Usage: "PEM private key of --" + prefix + "-tls-cert"},
+		cli.StringFlag{Name: prefix + "-tls-server-name", Usage: "name expected in the " + connection + " server certificate (host by default)"},
+		cli.StringFlag{Name: prefix + "-tls-min-version", Value: "1.2", Usage: "minimum TLS version of the " + connection + " (1.0, 1.1 or 1.2)"},
+		cli.BoolFlag{Name: prefix + "-tls-insecure", Usage: "do not verify the " + connection + " server certificate (insecure)"},
+	}
+}
+
+func tlsConfig(c *cli.Context, prefix string) (*tls.Config, error) {
+	o := &tlsconfig.Options{
+		CAFile:     c.String(prefix + "-tls-ca"),
+		CertFile:   c.String(prefix + "-tls-cert"),
+		KeyFile:    c.String(prefix + "-tls-key"),
+		ServerName: c.String(prefix + "-tls-server-name"),
+		MinVersion: c.String(prefix + "-tls-min-version"),
+		Insecure:   c.Bool(prefix + "-tls-insecure"),
+	}
+	config, err := o.Client()
+	if err != nil {
+		return nil, fmt.Errorf("--%s-tls options: %v", prefix, err)
+	}
+	return config, nil
+}
+
+// write then rename, readers never see a partial file
+func writeFileAtomic(path string, b []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/robinmonjo/dock/notifier"
+	"github.com/robinmonjo/dock/procfs"
+)
+
+const (
+	watchdogRestart         = "restart"
+	defaultWatchdogInterval = 5 * time.Second
+)
+
+// watchdog periodically checks the resources used by dock's descendants against thresholds.
+// On breach, a warning is notified and the process is either signaled or restarted
+type watchdog struct {
+	maxRSS   uint64        // bytes, 0 means no limit
+	maxCPU   time.Duration // 0 means no limit
+	interval time.Duration
+	restart  bool
+	signal   syscall.Signal
+}
+
+// usage models the resources used by a process tree
+type usage struct {
+	rss         uint64
+	cpuTime     time.Duration
+	descendants int
+	fds         int
+}
+
+// newWatchdog parses watchdog flags. action is either "restart" or a signal name (i.e. TERM or SIGKILL)
+func newWatchdog(maxRSS string, maxCPUSeconds int, interval, action string) (*watchdog, error) {
+	w := &watchdog{
+		maxCPU:   time.Duration(maxCPUSeconds) * time.Second,
+		interval: defaultWatchdogInterval,
+	}
+
+	if maxRSS != "" {
+		size, err := parseSize(maxRSS)
+		if err != nil {
+			return nil, err
+		}
+		w.maxRSS = size
+	}
+
+	if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("watchdog interval must be positive, got %s", interval)
+		}
+		w.interval = d
+	}
+
+	if action == watchdogRestart {
+		w.restart = true
+	} else {
+		sig, err := parseSignal(action)
+		if err != nil {
+			return nil, err
+		}
+		w.signal = sig
+	}
+	return w, nil
+}
+
+// watch the resources used until the stop channel is closed. Act once per breach, the process
+// is ready again once its usage is back under the thresholds (if it was ready before)
+func (w *watchdog) watch(p *process, stop <-chan bool) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	breached, wasReady := false, false
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		// the process acted upon is the one measured, not one started meanwhile
+		pid := p.runningPid()
+		u, err := treeUsage(procfs.Self())
+		if err != nil {
+			watchdogLog.Error(err)
+			continue
+		}
+		watchdogLog.Debugf("rss %d bytes, cpu time %v", u.rss, u.cpuTime)
+
+		breach := w.check(u)
+		if breach == "" {
+			if breached {
+				breached = false
+				watchdogLog.WithField("pid", pid).Info("usage back under the thresholds")
+				if wasReady && lifecycle.State() == notifier.StatusUnhealthy {
+					processStateChanged(&notifier.Ps{Status: notifier.StatusReady})
+				}
+			}
+			continue
+		}
+		if breached {
+			continue
+		}
+
+		breached, wasReady = true, lifecycle.State() == notifier.StatusReady
+		watchdogLog.WithFields(log.Fields{"pid": pid, "state": notifier.StatusUnhealthy}).Warn(breach)
+		processStateChanged(&notifier.Ps{
+			Status:  notifier.StatusUnhealthy,
+			Message: breach,
+		})
+
+		if pid == 0 {
+			continue //not running, nothing to act upon
+		}
+		if w.restart {
+			err = p.restartRunning(pid)
+		} else {
+			err = p.signalRunning(pid, w.signal)
+		}
+		if err == errNotRunning || err == errStopping {
+			watchdogLog.WithField("pid", pid).Debugf("no action taken: %v", err)
+		} else if err != nil {
+			watchdogLog.Error(err)
+		}
+	}
+}
+
+// returns a description of the breached threshold, if any
+func (w *watchdog) check(u *usage) string {
+	if w.maxRSS > 0 && u.rss > w.maxRSS {
+		return fmt.Sprintf("resident memory %d bytes exceeds limit of %d bytes", u.rss, w.maxRSS)
+	}
+	if w.maxCPU > 0 && u.cpuTime > w.maxCPU {
+		return fmt.Sprintf("cpu time %v exceeds limit of %v", u.cpuTime, w.maxCPU)
+	}
+	return ""
+}
+
+// sum the resources used by the descendants of the given process. CPU time includes the time of
+// descendants' children that have already been waited for
+func treeUsage(p *procfs.Proc) (*usage, error) {
+	descendants, err := p.Descendants()
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	u := &usage{}
+	for _, d := range descendants {
+		stat, err := d.Stat()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue //process exited meanwhile
+			}
+			return nil, err
+		}
+		u.rss += uint64(stat.RSS) * pageSize
+		u.cpuTime += procfs.TicksToDuration(stat.UTime + stat.STime + stat.CUTime + stat.CSTime)
+		u.descendants++
+
+		fds, err := d.Fds()
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue //exited meanwhile, or run by another user
+			}
+			return nil, err
+		}
+		u.fds += len(fds)
+	}
+	return u, nil
+}
+
+func (u *usage) resources() *notifier.Resources {
+	return &notifier.Resources{
+		RSS:         u.rss,
+		CPUTime:     u.cpuTime.Seconds(),
+		Descendants: u.descendants,
+		OpenFds:     u.fds,
+	}
+}
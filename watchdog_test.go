@@ -0,0 +1,123 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected uint64
+		valid    bool
+	}{
+		{"0", 0, true},
+		{"512", 512, true},
+		{"512b", 512, true},
+		{"64k", 64 << 10, true},
+		{"64KB", 64 << 10, true},
+		{"64KiB", 64 << 10, true},
+		{" 256m ", 256 << 20, true},
+		{"256MB", 256 << 20, true},
+		{"2g", 2 << 30, true},
+		{"1T", 1 << 40, true},
+		{"", 0, false},
+		{"m", 0, false},
+		{"-1m", 0, false},
+		{"1.5g", 0, false},
+		{"12x", 0, false},
+	}
+
+	for _, test := range tests {
+		size, err := parseSize(test.size)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %d", test.size, size)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.size, err)
+			continue
+		}
+		if size != test.expected {
+			t.Errorf("%q: expected %d, got %d", test.size, test.expected, size)
+		}
+	}
+}
+
+func TestNewWatchdog(t *testing.T) {
+	tests := []struct {
+		maxRSS   string
+		maxCPU   int
+		interval string
+		action   string
+		expected *watchdog //nil if invalid
+	}{
+		{"", 0, "", "restart", &watchdog{interval: defaultWatchdogInterval, restart: true}},
+		{"256m", 0, "", "restart", &watchdog{maxRSS: 256 << 20, interval: defaultWatchdogInterval, restart: true}},
+		{"", 60, "1s", "TERM", &watchdog{maxCPU: time.Minute, interval: time.Second, signal: syscall.SIGTERM}},
+		{"1g", 10, "500ms", "SIGKILL", &watchdog{maxRSS: 1 << 30, maxCPU: 10 * time.Second, interval: 500 * time.Millisecond, signal: syscall.SIGKILL}},
+		{"", 0, "", "10", &watchdog{interval: defaultWatchdogInterval, signal: syscall.SIGUSR1}},
+
+		{"lots", 0, "", "restart", nil},
+		{"", 0, "often", "restart", nil},
+		{"", 0, "0s", "restart", nil},
+		{"", 0, "-1s", "restart", nil},
+		{"", 0, "", "reboot", nil},
+		{"", 0, "", "", nil},
+	}
+
+	for _, test := range tests {
+		w, err := newWatchdog(test.maxRSS, test.maxCPU, test.interval, test.action)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("%v: expected an error", test)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test, err)
+			continue
+		}
+		if *w != *test.expected {
+			t.Errorf("%v: expected %+v, got %+v", test, *test.expected, *w)
+		}
+	}
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	tests := []struct {
+		maxRSS uint64
+		maxCPU time.Duration
+		rss    uint64
+		cpu    time.Duration
+		breach bool
+	}{
+		// 0 means no limit
+		{0, 0, 1 << 40, time.Hour, false},
+
+		{1 << 20, 0, 1 << 19, time.Hour, false},
+		{1 << 20, 0, 1 << 20, time.Hour, false},
+		{1 << 20, 0, 1<<20 + 1, 0, true},
+
+		{0, time.Minute, 1 << 40, time.Minute, false},
+		{0, time.Minute, 0, time.Minute + time.Millisecond, true},
+
+		{1 << 20, time.Minute, 1 << 10, time.Second, false},
+		{1 << 20, time.Minute, 1 << 30, time.Second, true},
+		{1 << 20, time.Minute, 1 << 10, time.Hour, true},
+	}
+
+	for _, test := range tests {
+		w := &watchdog{maxRSS: test.maxRSS, maxCPU: test.maxCPU}
+		breach := w.check(&usage{rss: test.rss, cpuTime: test.cpu})
+		if test.breach && breach == "" {
+			t.Errorf("%+v: expected a breach", test)
+		}
+		if !test.breach && breach != "" {
+			t.Errorf("%+v: unexpected breach %q", test, breach)
+		}
+	}
+}